@@ -0,0 +1,207 @@
+package redpacket
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// moveAbort the named abort constant of the move module
+type moveAbort struct {
+	Name    string
+	Message string
+	Code    ErrorCode // empty if the abort isn't one of the classified errors
+}
+
+// redPacketMoveAborts the abort constants of the aptos and sui red_packet module
+var redPacketMoveAborts = map[uint64]moveAbort{
+	1:  {"EREDPACKET_HAS_PUBLISHED", "red packet config has been published", ""},
+	2:  {"EREDPACKET_NOT_PUBLISHED", "red packet config is not published", ""},
+	3:  {"EREDPACKET_PERMISSION_DENIED", "permission denied, the sender is not the admin", ""},
+	4:  {"EREDPACKET_ACCOUNTS_BALANCES_MISMATCH", "the number of opened addresses is not the same as the amounts", ErrorCodeInvalidParams},
+	5:  {"EREDPACKET_INSUFFICIENT_BALANCES", "the opened amount exceeds the remain balance", ErrorCodePacketExhausted},
+	6:  {"EREDPACKET_NOT_FOUND", "red packet not found", ErrorCodePacketNotFound},
+	7:  {"EREDPACKET_ACCOUNT_TOO_MANY", "count exceeds max_count or the remain count", ErrorCodeInvalidParams},
+	8:  {"EREDPACKET_BALANCE_TOO_LITTLE", "the red packet balance is too little", ErrorCodeInvalidParams},
+	9:  {"EREDPACKET_HAS_REGISTERED", "the coin has been registered", ""},
+	10: {"EREDPACKET_COIN_TYPE_MISMATCH", "the coin type is not the same as the red packet", ErrorCodeUnsupportedToken},
+}
+
+// frameworkMoveAborts the aborts of the framework modules which the red packet calls, key is the module name
+var frameworkMoveAborts = map[string]map[uint64]moveAbort{
+	"coin": { // aptos 0x1::coin
+		6: {"EINSUFFICIENT_BALANCE", "not enough coins to complete transaction", ErrorCodeInsufficientBalance},
+	},
+	"balance": { // sui 0x2::balance
+		2: {"ENotEnough", "not enough balance to complete transaction", ErrorCodeInsufficientBalance},
+	},
+}
+
+// ethRevertReasons map the known revert reasons of the eth red packet and the erc20 tokens
+var ethRevertReasons = map[string]ErrorCode{
+	"ERC20: insufficient allowance":            ErrorCodeInsufficientBalance,
+	"ERC20: transfer amount exceeds allowance": ErrorCodeInsufficientBalance,
+	"ERC20: transfer amount exceeds balance":   ErrorCodeInsufficientBalance,
+}
+
+var (
+	// aptos vm_status, e.g. Move abort in 0x1::coin: EINSUFFICIENT_BALANCE(0x10006): Not enough coins to complete transaction
+	aptosAbortRegexp = regexp.MustCompile(`Move abort in (0x[0-9a-fA-F]+)::(\w+): (?:(\w+)\()?(0x[0-9a-fA-F]+|\d+)\)?(?:: (.*))?`)
+	// sui execution error, e.g. MoveAbort(MoveLocation { module: ModuleId { address: 5b2b.., name: Identifier("red_packet") }, .. }, 7) in command 1
+	suiAbortRegexp = regexp.MustCompile(`MoveAbort\(MoveLocation \{ module: ModuleId \{ address: (?:0x)?([0-9a-fA-F]+), name: Identifier\("(\w+)"\) \}.*?\}, (\d+)\)`)
+	// eth rpc error of the call and estimate, e.g. execution reverted: ERC20: insufficient allowance
+	ethRevertRegexp = regexp.MustCompile(`execution reverted(?:: (.*))?`)
+)
+
+// DecodeContractAbort decode the failure message of the transaction or the dry-run error,
+// the move abort of the red_packet module is named by the abort code,
+// return nil if the message isn't a contract abort.
+func DecodeContractAbort(message string) *ContractAbortError {
+	if match := aptosAbortRegexp.FindStringSubmatch(message); match != nil {
+		code, err := strconv.ParseUint(strings.TrimPrefix(match[4], "0x"), abortCodeBase(match[4]), 64)
+		if err != nil {
+			return nil
+		}
+		abort := newMoveAbortError(match[1], match[2], code)
+		if abort.Name == "" {
+			abort.Name = match[3]
+		}
+		if abort.Reason == "" {
+			abort.Reason = match[5]
+		}
+		return abort
+	}
+	if match := suiAbortRegexp.FindStringSubmatch(message); match != nil {
+		code, err := strconv.ParseUint(match[3], 10, 64)
+		if err != nil {
+			return nil
+		}
+		return newMoveAbortError("0x"+match[1], match[2], code)
+	}
+	if match := ethRevertRegexp.FindStringSubmatch(message); match != nil {
+		return newEthRevertError(match[1])
+	}
+	return nil
+}
+
+func abortCodeBase(code string) int {
+	if strings.HasPrefix(code, "0x") {
+		return 16
+	}
+	return 10
+}
+
+// newMoveAbortError name the abort code of the module, the aptos abort code contains the error category
+// in the high bits, e.g. error::invalid_argument(7) is 0x10007, only the reason in the low 16 bits is matched.
+func newMoveAbortError(address, module string, code uint64) *ContractAbortError {
+	abort := &ContractAbortError{
+		AbortCode: code,
+		Location:  address + "::" + module,
+	}
+	aborts := frameworkMoveAborts[module]
+	if module == "red_packet" {
+		aborts = redPacketMoveAborts
+	}
+	if named, ok := aborts[code&0xffff]; ok {
+		abort.Name = named.Name
+		abort.Reason = named.Message
+		abort.Code = named.Code
+	}
+	return abort
+}
+
+func newEthRevertError(reason string) *ContractAbortError {
+	if reason == "" {
+		reason = "execution reverted"
+	}
+	abort := &ContractAbortError{Reason: reason}
+	for known, code := range ethRevertReasons {
+		if strings.Contains(reason, known) {
+			abort.Code = code
+			break
+		}
+	}
+	return abort
+}
+
+// panicSelector the selector of Panic(uint256) which is raised by the solidity assert and the arithmetic checks
+var panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+
+var ethPanicReasons = map[uint64]string{
+	0x01: "assert failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x32: "array index out of bounds",
+}
+
+// decodeEthRevertData decode the revert data of Error(string) or Panic(uint256)
+func decodeEthRevertData(data []byte) (string, bool) {
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return reason, true
+	}
+	if len(data) == 4+32 && string(data[:4]) == string(panicSelector) {
+		code := big.NewInt(0).SetBytes(data[4:])
+		if reason, ok := ethPanicReasons[code.Uint64()]; code.IsUint64() && ok {
+			return fmt.Sprintf("panic: %s (0x%x)", reason, code), true
+		}
+		return fmt.Sprintf("panic: code 0x%x", code), true
+	}
+	return "", false
+}
+
+// ethRevertError decode the revert of the eth_call or eth_estimateGas error, return nil if it isn't reverted.
+// The revert data which is neither Error(string) nor Panic(uint256) is a custom error named by it's selector.
+func ethRevertError(err error) *ContractAbortError {
+	data := ethRevertData(err)
+	if reason, ok := decodeEthRevertData(data); ok {
+		return newEthRevertError(reason)
+	}
+	if len(data) >= 4 {
+		return newEthRevertError(fmt.Sprintf("custom error 0x%x", data[:4]))
+	}
+	return DecodeContractAbort(err.Error())
+}
+
+// ethRevertData the revert data in the rpc error, nil if there is none
+func ethRevertData(err error) []byte {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil
+	}
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return nil
+	}
+	bytes, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil {
+		return nil
+	}
+	return bytes
+}
+
+// dryRunError replace the dry-run error with the decoded contract abort, other errors are returned as is
+func dryRunError(err error) error {
+	if err == nil || ErrorCodeOf(err) != "" {
+		return err
+	}
+	if abort := DecodeContractAbort(err.Error()); abort != nil {
+		return abort
+	}
+	return err
+}
+
+// transactionAbort decode the failure message of the failed transaction, nil if it's not failed
+func transactionAbort(detail *base.TransactionDetail) *ContractAbortError {
+	if detail == nil || detail.Status != base.TransactionStatusFailure {
+		return nil
+	}
+	return DecodeContractAbort(detail.FailureMessage)
+}
@@ -0,0 +1,104 @@
+package redpacket
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeContractAbort_Aptos(t *testing.T) {
+	abort := DecodeContractAbort("Move abort in 0x2b0f::red_packet: 0x10007")
+	require.NotNil(t, abort)
+	require.Equal(t, uint64(0x10007), abort.AbortCode)
+	require.Equal(t, "0x2b0f::red_packet", abort.Location)
+	require.Equal(t, "EREDPACKET_ACCOUNT_TOO_MANY", abort.Name)
+	require.Equal(t, "count exceeds max_count or the remain count", abort.Reason)
+	require.ErrorIs(t, abort, ErrInvalidParams)
+	require.ErrorIs(t, abort, ErrContractAbort)
+	require.Equal(t, ErrorCodeInvalidParams, ErrorCodeOf(abort))
+
+	abort = DecodeContractAbort("Move abort in 0x1::coin: EINSUFFICIENT_BALANCE(0x10006): Not enough coins to complete transaction")
+	require.NotNil(t, abort)
+	require.Equal(t, "EINSUFFICIENT_BALANCE", abort.Name)
+	require.ErrorIs(t, abort, ErrInsufficientBalance)
+
+	// the unknown abort keep the name and message of the vm_status
+	abort = DecodeContractAbort("Move abort in 0x1::account: ESEQUENCE_NUMBER_TOO_BIG(0x10003): Sequence number exceeds the maximum value")
+	require.NotNil(t, abort)
+	require.Equal(t, "ESEQUENCE_NUMBER_TOO_BIG", abort.Name)
+	require.Equal(t, "Sequence number exceeds the maximum value", abort.Reason)
+	require.Equal(t, ErrorCodeContractAbort, ErrorCodeOf(abort))
+
+	require.Nil(t, DecodeContractAbort("Out of gas"))
+}
+
+func TestDecodeContractAbort_Sui(t *testing.T) {
+	status := `MoveAbort(MoveLocation { module: ModuleId { address: 5b2b8c1e0f3a4d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6, name: Identifier("red_packet") }, function: 2, instruction: 26, function_name: Some("open") }, 5) in command 0`
+	abort := DecodeContractAbort(status)
+	require.NotNil(t, abort)
+	require.Equal(t, uint64(5), abort.AbortCode)
+	require.Equal(t, fixtureSuiPackage+"::red_packet", abort.Location)
+	require.Equal(t, "EREDPACKET_INSUFFICIENT_BALANCES", abort.Name)
+	require.ErrorIs(t, abort, ErrPacketExhausted)
+	require.EqualError(t, abort, "contract abort in "+fixtureSuiPackage+"::red_packet with EREDPACKET_INSUFFICIENT_BALANCES(5): the opened amount exceeds the remain balance")
+}
+
+func TestDecodeContractAbort_Eth(t *testing.T) {
+	abort := DecodeContractAbort("execution reverted: ERC20: insufficient allowance")
+	require.NotNil(t, abort)
+	require.Equal(t, "ERC20: insufficient allowance", abort.Reason)
+	require.ErrorIs(t, abort, ErrInsufficientBalance)
+
+	abort = DecodeContractAbort("execution reverted")
+	require.NotNil(t, abort)
+	require.Equal(t, ErrorCodeContractAbort, ErrorCodeOf(abort))
+}
+
+func Test_decodeEthRevertData(t *testing.T) {
+	// Error("invalid count")
+	data, _ := hex.DecodeString("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000d" +
+		"696e76616c696420636f756e7400000000000000000000000000000000000000")
+	reason, ok := decodeEthRevertData(data)
+	require.True(t, ok)
+	require.Equal(t, "invalid count", reason)
+
+	// Panic(0x11)
+	data, _ = hex.DecodeString("4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011")
+	reason, ok = decodeEthRevertData(data)
+	require.True(t, ok)
+	require.Equal(t, "panic: arithmetic overflow or underflow (0x11)", reason)
+
+	_, ok = decodeEthRevertData([]byte{0x01})
+	require.False(t, ok)
+}
+
+func Test_dryRunError(t *testing.T) {
+	err := dryRunError(errors.New("Move abort in 0x2b0f::red_packet: EREDPACKET_NOT_FOUND(0x60006)"))
+	require.ErrorIs(t, err, ErrPacketNotFound)
+
+	invalidErr := newInvalidParamsError("invalid count")
+	require.Equal(t, invalidErr, dryRunError(invalidErr))
+	otherErr := errors.New("insufficient gas")
+	require.Equal(t, otherErr, dryRunError(otherErr))
+	require.Nil(t, dryRunError(nil))
+}
+
+func TestTransactionRevertedError_Abort(t *testing.T) {
+	reason := "Move abort in 0x2b0f::red_packet: 0x10007"
+	err := fmt.Errorf("confirm: %w", &TransactionRevertedError{Hash: "0x1", Reason: reason, Abort: DecodeContractAbort(reason)})
+	require.ErrorIs(t, err, ErrContractAbort)
+	require.ErrorIs(t, err, ErrInvalidParams)
+	require.Equal(t, ErrorCodeInvalidParams, ErrorCodeOf(err))
+	require.EqualError(t, err, "confirm: transaction 0x1 reverted: EREDPACKET_ACCOUNT_TOO_MANY(count exceeds max_count or the remain count)")
+
+	detail := &base.TransactionDetail{Status: base.TransactionStatusFailure, FailureMessage: reason}
+	require.Equal(t, "EREDPACKET_ACCOUNT_TOO_MANY", transactionAbort(detail).Name)
+	detail.Status = base.TransactionStatusSuccess
+	require.Nil(t, transactionAbort(detail))
+}
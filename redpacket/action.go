@@ -1,9 +1,13 @@
 package redpacket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/coming-chat/wallet-SDK/core/base"
 )
@@ -12,13 +16,93 @@ const (
 	RPAMethodCreate = "create"
 	RPAMethodOpen   = "open"
 	RPAMethodClose  = "close"
+
+	// RPAMethodApprove approve the red packet contract to spend the erc20 token, only eth need it
+	RPAMethodApprove = "approve"
+)
+
+// eth 红包合约的管理操作，方法名与合约 abi 一致
+const (
+	RPAMethodSetAdmin          = "set_admin"
+	RPAMethodSetBeneficiary    = "set_beneficiary"
+	RPAMethodSetPrepaidFee     = "set_prepaid_fee"
+	RPAMethodTransferOwnership = "transferOwnership"
+	RPAMethodRenounceOwnership = "renounceOwnership"
 )
 
+// event_type of aptos/sui RedPacketEvent
+const (
+	redPacketEventCreate = 0
+	redPacketEventOpen   = 1
+	redPacketEventClose  = 2
+)
+
+func redPacketEventName(eventType int) string {
+	switch eventType {
+	case redPacketEventCreate:
+		return RPAMethodCreate
+	case redPacketEventOpen:
+		return RPAMethodOpen
+	case redPacketEventClose:
+		return RPAMethodClose
+	default:
+		return strconv.Itoa(eventType)
+	}
+}
+
 type RedPacketContract interface {
 	SendTransaction(base.Account, *RedPacketAction) (string, error)
 	FetchRedPacketCreationDetail(hash string) (*RedPacketDetail, error)
 	EstimateFee(*RedPacketAction) (string, error)                  // create red packet fee
 	EstimateGasFee(base.Account, *RedPacketAction) (string, error) // gas fee = gasPrice * gasLimit
+	// aptos/eth use packetId, sui use packetObjectId, aptos need tokenAddress to find the packet handler
+	FetchRedPacketState(tokenAddress string, packetId int64, packetObjectId string) (*RedPacketState, error)
+	FetchRedPacketOpenDetail(hash string) (*RedPacketOpenDetail, error)
+	FetchRedPacketCloseDetail(hash string) (*RedPacketCloseDetail, error)
+	// PrepareCreate return the action that must be sent before the create action, e.g. the eth erc20 approve,
+	// nil if the create action can be sent directly.
+	PrepareCreate(owner string, rpa *RedPacketAction) (*RedPacketAction, error)
+	// BuildUnsignedTransaction build the action transaction of sender without signing, for the signer outside, e.g. hsm
+	BuildUnsignedTransaction(sender string, rpa *RedPacketAction) (*UnsignedTransaction, error)
+	// SubmitSignedTransaction submit the transaction signed outside, return the transaction hash
+	SubmitSignedTransaction(signedTx *SignedTransaction) (string, error)
+	// SimulateAction dry-run the action of account without sending, the contract abort is returned in the result
+	SimulateAction(account base.Account, rpa *RedPacketAction) (*SimulateResult, error)
+	// QuoteCreate the amount, service fee and gas fee of the create action, and whether the balances of account cover them
+	QuoteCreate(account base.Account, rpa *RedPacketAction) (*CreateQuote, error)
+}
+
+// RedPacketContractCtx is the context aware version of RedPacketContract,
+// the context is passed down to the chain rpc calls for deadline and cancellation.
+type RedPacketContractCtx interface {
+	RedPacketContract
+
+	SendTransactionWithContext(context.Context, base.Account, *RedPacketAction) (string, error)
+	FetchRedPacketCreationDetailWithContext(ctx context.Context, hash string) (*RedPacketDetail, error)
+	EstimateFeeWithContext(context.Context, *RedPacketAction) (string, error)
+	EstimateGasFeeWithContext(context.Context, base.Account, *RedPacketAction) (string, error)
+	FetchRedPacketStateWithContext(ctx context.Context, tokenAddress string, packetId int64, packetObjectId string) (*RedPacketState, error)
+	FetchRedPacketOpenDetailWithContext(ctx context.Context, hash string) (*RedPacketOpenDetail, error)
+	FetchRedPacketCloseDetailWithContext(ctx context.Context, hash string) (*RedPacketCloseDetail, error)
+	PrepareCreateWithContext(ctx context.Context, owner string, rpa *RedPacketAction) (*RedPacketAction, error)
+	BuildUnsignedTransactionWithContext(ctx context.Context, sender string, rpa *RedPacketAction) (*UnsignedTransaction, error)
+	SubmitSignedTransactionWithContext(ctx context.Context, signedTx *SignedTransaction) (string, error)
+	SimulateActionWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*SimulateResult, error)
+	QuoteCreateWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*CreateQuote, error)
+	// WaitForConfirmation poll the transaction until it's confirmed, and decode the red packet result by opts.Method.
+	// return *TransactionRevertedError, *TransactionTimeoutError or *TransactionDroppedError if not confirmed success.
+	WaitForConfirmation(ctx context.Context, hash string, opts *ConfirmOptions) (*RedPacketConfirmation, error)
+}
+
+// RedPacketBatchContract send several actions in one atomic transaction, all actions succeed or fail together.
+// It's implemented by the sui contract, get it by the type assertion of the RedPacketContract.
+type RedPacketBatchContract interface {
+	// SendBatch send the create/open/close actions in one transaction with one gas payment, return the transaction hash
+	SendBatch(account base.Account, rpas []*RedPacketAction) (string, error)
+	SendBatchWithContext(ctx context.Context, account base.Account, rpas []*RedPacketAction) (string, error)
+	// FetchBatchResult decode the result of each action from the events of the batch transaction
+	FetchBatchResult(hash string) (*BatchResult, error)
+	FetchBatchResultWithContext(ctx context.Context, hash string) (*BatchResult, error)
 }
 
 type RedPacketAction struct {
@@ -27,6 +111,9 @@ type RedPacketAction struct {
 	CreateParams *RedPacketCreateParams
 	OpenParams   *RedPacketOpenParams
 	CloseParams  *RedPacketCloseParams
+
+	ApproveParams *RedPacketApproveParams
+	AdminParams   *RedPacketAdminParams
 }
 
 func (a *RedPacketAction) TokenAddress() string {
@@ -37,13 +124,15 @@ func (a *RedPacketAction) TokenAddress() string {
 		return a.OpenParams.TokenAddress
 	case RPAMethodClose:
 		return a.CloseParams.TokenAddress
+	case RPAMethodApprove:
+		return a.ApproveParams.TokenAddress
 	default:
 		return ""
 	}
 }
 
 type RedPacketCreateParams struct {
-	TokenAddress string // erc20 tokenAddress, aptos coin type
+	TokenAddress string // erc20 tokenAddress (zero address is the native coin), aptos coin type
 	Count        int
 	Amount       string
 }
@@ -61,6 +150,16 @@ type RedPacketCloseParams struct {
 	Creator        string
 }
 
+type RedPacketApproveParams struct {
+	TokenAddress string // erc20 tokenAddress
+	Amount       string // the allowance of the red packet contract
+}
+
+type RedPacketAdminParams struct {
+	Address string // new admin, beneficiary or owner
+	Fee     string // new base prepaid fee
+}
+
 type RedPacketDetail struct {
 	*base.TransactionDetail
 
@@ -68,16 +167,129 @@ type RedPacketDetail struct {
 	AmountDecimal   int16
 	RedPacketAmount string // 最后加入到红包里的 Amount，也即用户能够抢的那部分的 Amount
 	ChainName       string
+	PacketId        int64               // aptos/eth 创建的红包 id
+	PacketObjectId  string              // sui 创建的红包 object id
+	Abort           *ContractAbortError // 失败交易解码出的合约错误，e.g. count exceeds max_count，成功的交易为 nil
+}
+
+// RedPacketOpenDetail 打开红包交易的详情
+type RedPacketOpenDetail struct {
+	*base.TransactionDetail
+
+	AmountName     string
+	AmountDecimal  int16
+	ChainName      string
+	PacketId       int64    // aptos/eth use packetId
+	PacketObjectId string   // sui use packetObjectId
+	Addresses      []string // 抢到红包的地址，与 Amounts 一一对应
+	Amounts        []string
+	RemainCount    int64               // 打开之后红包剩余的个数
+	RemainBalance  string              // 打开之后红包剩余的 Amount
+	Abort          *ContractAbortError // 失败交易解码出的合约错误
+}
+
+// RedPacketCloseDetail 关闭红包交易的详情
+type RedPacketCloseDetail struct {
+	*base.TransactionDetail
+
+	AmountName     string
+	AmountDecimal  int16
+	ChainName      string
+	PacketId       int64               // aptos/eth use packetId
+	PacketObjectId string              // sui use packetObjectId
+	Creator        string              // 收到退款的红包创建者
+	RefundAmount   string              // 关闭红包时退还给创建者的 Amount
+	Abort          *ContractAbortError // 失败交易解码出的合约错误
+}
+
+// RedPacketState 红包在链上的当前状态
+type RedPacketState struct {
+	TokenAddress  string
+	Creator       string // 链上没有记录创建者时为空 (eth/aptos)
+	RemainCount   int64
+	RemainBalance string
+	Valid         bool // 红包已经被抢完时为 false，已关闭的红包返回 ErrPacketClosed (eth) 或者 ErrPacketNotFound (aptos/sui 关闭后删除)
+}
+
+// UnsignedTransaction 未签名的交易，以及签名需要的元数据
+type UnsignedTransaction struct {
+	ChainName string
+	Sender    string
+	// eth: RLP of the unsigned legacy transaction, aptos: BCS RawTransaction, sui: BCS TransactionData
+	Data []byte
+	// the message to sign, eth: the EIP155 signing hash, aptos: the salt prefixed RawTransaction,
+	// sui: the intent message, sign it's blake2b hash
+	SigningMessage []byte
+
+	Nonce          uint64 // eth nonce, aptos sequence number, sui has no nonce
+	GasLimit       uint64 // eth gas limit, aptos max gas amount, sui gas budget is in Data
+	GasPrice       string // eth gas price, aptos gas unit price
+	ChainId        string // eth/aptos chain id
+	Expiration     int64  // aptos expiration timestamp in seconds
+	EstimateGasFee string
+}
+
+// SignedTransaction 外部签名之后的交易
+type SignedTransaction struct {
+	// eth: RLP of the signed transaction, aptos: BCS SignedTransaction, sui: the UnsignedTransaction.Data
+	Data []byte
+	// sui only: the serialized signature, flag || signature || public key
+	Signature []byte
+}
+
+// SimulateResult 模拟执行交易的结果，用于签名之前提示用户
+type SimulateResult struct {
+	Success        bool
+	FailureMessage string              // 执行失败时链上返回的原因
+	Abort          *ContractAbortError // 执行失败时解码出的合约错误
+	EstimateGasFee string              // 模拟执行消耗的 gas fee
+	BalanceChanges []*BalanceChange    // eth 的 eth_call 没有状态变化，只有 create 根据 action 推算
+	Events         []*RedPacketEvent   // 红包合约的事件，eth 为空
+}
+
+// BalanceChange 交易执行之后地址的余额变化
+type BalanceChange struct {
+	Address      string
+	TokenAddress string
+	Amount       string // 减少为负数
+}
+
+// RedPacketEvent 红包合约的 create/open/close 事件
+type RedPacketEvent struct {
+	Method         string
+	PacketId       int64  // aptos 红包 id
+	PacketObjectId string // sui 红包 object id
+	RemainCount    int64
+	RemainBalance  string
+}
+
+// BatchResult 批量交易的结果
+type BatchResult struct {
+	*base.TransactionDetail
+	Abort  *ContractAbortError // 交易失败时解码出的合约错误
+	Events []*RedPacketEvent   // 按 action 的顺序，每个 action 一个事件，交易失败时为空
+}
+
+// failedSimulateResult the result of the aborted dry-run, other errors are returned as is
+func failedSimulateResult(err error) (*SimulateResult, error) {
+	var abort *ContractAbortError
+	if !errors.As(err, &abort) {
+		return nil, err
+	}
+	return &SimulateResult{
+		FailureMessage: abort.Error(),
+		Abort:          abort,
+	}, nil
 }
 
 // 用户发红包 的操作
 func NewRedPacketActionCreate(tokenAddress string, count int, amount string) (*RedPacketAction, error) {
 	_, ok := big.NewInt(0).SetString(amount, 10)
 	if !ok {
-		return nil, fmt.Errorf("invalid red packet amount %v", amount)
+		return nil, newInvalidParamsError("invalid red packet amount %v", amount)
 	}
 	if tokenAddress == "" {
-		return nil, fmt.Errorf("tokenAddress must not empty")
+		return nil, newInvalidParamsError("tokenAddress must not empty")
 	}
 	return &RedPacketAction{
 		Method: RPAMethodCreate,
@@ -89,15 +301,78 @@ func NewRedPacketActionCreate(tokenAddress string, count int, amount string) (*R
 	}, nil
 }
 
+// NewRedPacketActionApprove 授权红包合约使用用户的 erc20 token, 仅 eth 需要
+func NewRedPacketActionApprove(tokenAddress string, amount string) (*RedPacketAction, error) {
+	_, ok := big.NewInt(0).SetString(amount, 10)
+	if !ok {
+		return nil, newInvalidParamsError("invalid approve amount %v", amount)
+	}
+	if tokenAddress == "" {
+		return nil, newInvalidParamsError("tokenAddress must not empty")
+	}
+	return &RedPacketAction{
+		Method: RPAMethodApprove,
+		ApproveParams: &RedPacketApproveParams{
+			TokenAddress: tokenAddress,
+			Amount:       amount,
+		},
+	}, nil
+}
+
+// NewRedPacketActionSetAdmin 设置 eth 红包合约的 admin, 需要 owner 发送
+func NewRedPacketActionSetAdmin(newAdmin string) (*RedPacketAction, error) {
+	return newRedPacketAdminAddressAction(RPAMethodSetAdmin, newAdmin)
+}
+
+// NewRedPacketActionSetBeneficiary 设置 eth 红包合约服务费的收款地址, 需要 owner 发送
+func NewRedPacketActionSetBeneficiary(newBeneficiary string) (*RedPacketAction, error) {
+	return newRedPacketAdminAddressAction(RPAMethodSetBeneficiary, newBeneficiary)
+}
+
+// NewRedPacketActionTransferOwnership 转移 eth 红包合约的 owner
+func NewRedPacketActionTransferOwnership(newOwner string) (*RedPacketAction, error) {
+	return newRedPacketAdminAddressAction(RPAMethodTransferOwnership, newOwner)
+}
+
+// NewRedPacketActionRenounceOwnership 放弃 eth 红包合约的 owner, 之后不能再执行需要 owner 的操作
+func NewRedPacketActionRenounceOwnership() (*RedPacketAction, error) {
+	return &RedPacketAction{
+		Method:      RPAMethodRenounceOwnership,
+		AdminParams: &RedPacketAdminParams{},
+	}, nil
+}
+
+// NewRedPacketActionSetPrepaidFee 设置 eth 红包合约的 base_prepaid_fee, 需要 owner 发送
+func NewRedPacketActionSetPrepaidFee(fee string) (*RedPacketAction, error) {
+	_, ok := big.NewInt(0).SetString(fee, 10)
+	if !ok {
+		return nil, newInvalidParamsError("invalid prepaid fee %v", fee)
+	}
+	return &RedPacketAction{
+		Method:      RPAMethodSetPrepaidFee,
+		AdminParams: &RedPacketAdminParams{Fee: fee},
+	}, nil
+}
+
+func newRedPacketAdminAddressAction(method string, address string) (*RedPacketAction, error) {
+	if address == "" {
+		return nil, newInvalidParamsError("address must not empty")
+	}
+	return &RedPacketAction{
+		Method:      method,
+		AdminParams: &RedPacketAdminParams{Address: address},
+	}, nil
+}
+
 // 批量打开红包 的操作
 func NewRedPacketActionOpen(tokenAddress string, packetId int64, addresses []string, amounts []string) (*RedPacketAction, error) {
 	if len(addresses) != len(amounts) {
-		return nil, fmt.Errorf("the number of opened addresses is not the same as the amount")
+		return nil, newInvalidParamsError("the number of opened addresses is not the same as the amount")
 	}
 	for _, amount := range amounts {
 		_, ok := big.NewInt(0).SetString(amount, 10)
 		if !ok {
-			return nil, fmt.Errorf("invalid red packet amount %v", amount)
+			return nil, newInvalidParamsError("invalid red packet amount %v", amount)
 		}
 	}
 	return &RedPacketAction{
@@ -113,12 +388,12 @@ func NewRedPacketActionOpen(tokenAddress string, packetId int64, addresses []str
 
 func NewSuiRedpacketActionOpen(tokenAddress string, packetObjectId string, addresses []string, amounts []string) (*RedPacketAction, error) {
 	if len(addresses) != len(amounts) {
-		return nil, fmt.Errorf("the number of opened addresses is not the same as the amount")
+		return nil, newInvalidParamsError("the number of opened addresses is not the same as the amount")
 	}
 	for _, amount := range amounts {
 		_, ok := big.NewInt(0).SetString(amount, 10)
 		if !ok {
-			return nil, fmt.Errorf("invalid red packet amount %v", amount)
+			return nil, newInvalidParamsError("invalid red packet amount %v", amount)
 		}
 	}
 	return &RedPacketAction{
@@ -179,3 +454,71 @@ func NewRedPacketDetailWithJsonString(s string) (*RedPacketDetail, error) {
 	}
 	return &d, nil
 }
+
+func (s *RedPacketState) JsonString() string {
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+func (d *RedPacketOpenDetail) JsonString() string {
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+func (d *RedPacketCloseDetail) JsonString() string {
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+// redPacketEventRemain parse remain_count and remain_balance from aptos/sui RedPacketEvent data
+func redPacketEventRemain(eventData map[string]interface{}) (int64, string, error) {
+	remainCount, err := strconv.ParseInt(fmt.Sprint(eventData["remain_count"]), 10, 64)
+	if err != nil {
+		return 0, "", newRedPacketDataError("redpacket data remain_count is not u64")
+	}
+	remainBalance, ok := eventData["remain_balance"].(string)
+	if !ok {
+		return 0, "", newRedPacketDataError("redpacket data remain_balance is not string")
+	}
+	return remainCount, remainBalance, nil
+}
+
+// newRedPacketEvent parse the aptos/sui RedPacketEvent data, the aptos id is u64 and the sui id is the object id
+func newRedPacketEvent(eventData map[string]interface{}) (*RedPacketEvent, error) {
+	eventType, err := strconv.Atoi(fmt.Sprint(eventData["event_type"]))
+	if err != nil {
+		return nil, newRedPacketDataError("redpacket data event_type is not u8")
+	}
+	event := &RedPacketEvent{Method: redPacketEventName(eventType)}
+	id := fmt.Sprint(eventData["id"])
+	if strings.HasPrefix(id, "0x") {
+		event.PacketObjectId = id
+	} else if event.PacketId, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, newRedPacketDataError("redpacket data id is not u64")
+	}
+	event.RemainCount, event.RemainBalance, err = redPacketEventRemain(eventData)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// sumAmounts sum the amount strings, invalid amount is treated as zero
+func sumAmounts(amounts []string) string {
+	total := big.NewInt(0)
+	for _, amount := range amounts {
+		if a, ok := big.NewInt(0).SetString(amount, 10); ok {
+			total.Add(total, a)
+		}
+	}
+	return total.String()
+}
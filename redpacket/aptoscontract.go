@@ -1,17 +1,25 @@
 package redpacket
 
 import (
+	"context"
+	"crypto/ed25519"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/coming-chat/go-aptos/aptosclient"
 	"github.com/coming-chat/go-aptos/aptostypes"
 	txbuilder "github.com/coming-chat/go-aptos/transaction_builder"
 	"github.com/coming-chat/lcs"
 	"github.com/coming-chat/wallet-SDK/core/aptos"
 	"github.com/coming-chat/wallet-SDK/core/base"
+	"golang.org/x/crypto/sha3"
 )
 
 const (
@@ -24,20 +32,62 @@ const (
 	closeABIFormat  = "0105636c6f7365%s0a7265645f7061636b65742d2063616c6c20627920636f6d696e67636861742061646d696e0a20636c6f7365206120726564207061636b65740109636f696e5f74797065020d68616e646c65725f696e6465780202696402"
 )
 
-type tokenHandler struct {
-	CoinType     string
-	HandlerIndex uint64
-	FeePoint     uint64
+const (
+	// aptosMaxGasAmount the max gas amount of the unsigned transaction, the sender must have the balance for it
+	aptosMaxGasAmount = 20000
+	// aptosGasMarginPercent the max gas amount of the simulated transaction is the gas used * 150%,
+	// the state may change before the transaction is signed
+	aptosGasMarginPercent = 150
+	// aptosTransactionExpiration the unsigned transaction must be submitted before the expiration
+	aptosTransactionExpiration = 10 * time.Minute
+	// aptosCoinType the native coin which pays the gas
+	aptosCoinType = "0x1::aptos_coin::AptosCoin"
+)
+
+// defaultAptosTokenCacheDuration the default cache duration of the GlobalConfig handlers
+const defaultAptosTokenCacheDuration = time.Minute
+
+// AptosTokenHandler the red packet handler of a coin type in the aptos red packet GlobalConfig
+type AptosTokenHandler struct {
+	CoinType      string
+	HandlerIndex  uint64
+	FeePoint      uint64
+	BasePrepaid   string
+	EscrowAddress string
+	NextId        uint64 // the id of the next created red packet, may be outdated in the cache
+	StoreHandle   string // table handle of the red packet infos, keyed by packet id
+}
+
+// AptosRedPacketTokens list the supported coins of the aptos red packet contract,
+// the contract returned by NewAptosRedPacketContract can be asserted to it.
+type AptosRedPacketTokens interface {
+	ListSupportedTokens() ([]AptosTokenHandler, error)
+	ListSupportedTokensWithContext(ctx context.Context) ([]AptosTokenHandler, error)
+	// InvalidateTokenCache clear the cached handlers, the next call will fetch the GlobalConfig again
+	InvalidateTokenCache()
 }
 
-// aptosRedPacketContract implement RedPacketContract interface
+// aptosRedPacketContract implement RedPacketContract and RedPacketContractCtx interface
 type aptosRedPacketContract struct {
 	chain   aptos.IChain
 	address string
 	abi     *txbuilder.TransactionBuilderABI
+
+	tokenCacheDuration  time.Duration
+	tokenCacheLock      sync.Mutex
+	tokenCache          []AptosTokenHandler
+	tokenCacheExpiredAt time.Time
 }
 
 func NewAptosRedPacketContract(chain aptos.IChain, contractAddress string) RedPacketContract {
+	contract := newAptosRedPacketContract(chain, contractAddress, nil)
+	if contract == nil {
+		return nil
+	}
+	return contract
+}
+
+func newAptosRedPacketContract(chain aptos.IChain, contractAddress string, config *ContractConfig) *aptosRedPacketContract {
 	contractAddressWithOurPrefix := strings.TrimPrefix(contractAddress, "0x")
 	abiBytes := make([][]byte, 0)
 	abiStrFormats := []string{createABIFormat, openABIFormat, closeABIFormat}
@@ -50,36 +100,49 @@ func NewAptosRedPacketContract(chain aptos.IChain, contractAddress string) RedPa
 		return nil
 	}
 
-	return &aptosRedPacketContract{
-		chain:   chain,
-		address: "0x" + contractAddressWithOurPrefix,
-		abi:     redpacketAbi,
+	contract := &aptosRedPacketContract{
+		chain:              chain,
+		address:            "0x" + contractAddressWithOurPrefix,
+		abi:                redpacketAbi,
+		tokenCacheDuration: defaultAptosTokenCacheDuration,
 	}
+	if config != nil && config.AptosTokenCacheDuration != 0 {
+		contract.tokenCacheDuration = config.AptosTokenCacheDuration
+	}
+	return contract
 }
 
 func (contract *aptosRedPacketContract) EstimateFee(rpa *RedPacketAction) (string, error) {
+	return contract.EstimateFeeWithContext(context.Background(), rpa)
+}
+
+func (contract *aptosRedPacketContract) EstimateFeeWithContext(ctx context.Context, rpa *RedPacketAction) (string, error) {
 	switch rpa.Method {
 	case RPAMethodCreate:
 		if nil == rpa.CreateParams {
-			return "", errors.New("invalid create params")
+			return "", newInvalidParamsError("invalid create params")
 		}
 		amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
 		if err != nil {
-			return "", err
+			return "", newInvalidParamsError("amount params is not uint64")
 		}
-		handler, err := contract.getTokenHandler(rpa.CreateParams.TokenAddress)
+		handler, err := contract.getTokenHandler(ctx, rpa.CreateParams.TokenAddress)
 		if err != nil {
 			return "", err
 		}
 		total := calcTotal(amount, uint64(handler.FeePoint))
 		return strconv.FormatUint(total-amount, 10), nil
 	default:
-		return "", errors.New("method invalid")
+		return "", newInvalidParamsError("method invalid")
 	}
 }
 
 func (contract *aptosRedPacketContract) EstimateGasFee(acocunt base.Account, rpa *RedPacketAction) (string, error) {
-	payload, err := contract.createPayload(rpa)
+	return contract.EstimateGasFeeWithContext(context.Background(), acocunt, rpa)
+}
+
+func (contract *aptosRedPacketContract) EstimateGasFeeWithContext(ctx context.Context, acocunt base.Account, rpa *RedPacketAction) (string, error) {
+	payload, err := contract.createPayload(ctx, rpa)
 	if err != nil {
 		return "", err
 	}
@@ -87,112 +150,445 @@ func (contract *aptosRedPacketContract) EstimateGasFee(acocunt base.Account, rpa
 	if err != nil {
 		return "", err
 	}
-	gasFee, err := contract.chain.EstimatePayloadGasFeeBCS(acocunt, data)
+	gasFee, err := aptosCall(ctx, func() (*base.OptionalString, error) {
+		return contract.chain.EstimatePayloadGasFeeBCS(acocunt, data)
+	})
 	if err != nil {
-		return "", err
+		return "", dryRunError(err)
 	}
 	return gasFee.Value, nil
 }
 
-// getFeePoint get fee_point from contract by resouce
+func (contract *aptosRedPacketContract) ListSupportedTokens() ([]AptosTokenHandler, error) {
+	return contract.ListSupportedTokensWithContext(context.Background())
+}
+
+func (contract *aptosRedPacketContract) ListSupportedTokensWithContext(ctx context.Context) ([]AptosTokenHandler, error) {
+	return contract.tokenHandlers(ctx, false)
+}
+
+func (contract *aptosRedPacketContract) InvalidateTokenCache() {
+	contract.tokenCacheLock.Lock()
+	defer contract.tokenCacheLock.Unlock()
+	contract.tokenCache = nil
+}
+
+// getTokenHandler find the handler of the coin type, the cache is refreshed once if not found
+func (contract *aptosRedPacketContract) getTokenHandler(ctx context.Context, tokenAddress string) (AptosTokenHandler, error) {
+	handlers, err := contract.tokenHandlers(ctx, false)
+	if err != nil {
+		return AptosTokenHandler{}, err
+	}
+	if handler, ok := findAptosTokenHandler(handlers, tokenAddress); ok {
+		return handler, nil
+	}
+	if contract.tokenCacheDuration > 0 {
+		// the coin may be added after the handlers was cached
+		handlers, err = contract.tokenHandlers(ctx, true)
+		if err != nil {
+			return AptosTokenHandler{}, err
+		}
+		if handler, ok := findAptosTokenHandler(handlers, tokenAddress); ok {
+			return handler, nil
+		}
+	}
+	return AptosTokenHandler{}, newRedPacketError(ErrorCodeUnsupportedToken, "not found token handler of "+tokenAddress)
+}
+
+func findAptosTokenHandler(handlers []AptosTokenHandler, tokenAddress string) (AptosTokenHandler, bool) {
+	for _, handler := range handlers {
+		if handler.CoinType == tokenAddress {
+			return handler, true
+		}
+	}
+	return AptosTokenHandler{}, false
+}
+
+// tokenHandlers return a copy of the cached handlers, fetch them when the cache is expired or refresh is true
+func (contract *aptosRedPacketContract) tokenHandlers(ctx context.Context, refresh bool) ([]AptosTokenHandler, error) {
+	contract.tokenCacheLock.Lock()
+	if !refresh && contract.tokenCache != nil && time.Now().Before(contract.tokenCacheExpiredAt) {
+		handlers := append([]AptosTokenHandler{}, contract.tokenCache...)
+		contract.tokenCacheLock.Unlock()
+		return handlers, nil
+	}
+	contract.tokenCacheLock.Unlock()
+
+	handlers, err := contract.fetchTokenHandlers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if contract.tokenCacheDuration > 0 {
+		contract.tokenCacheLock.Lock()
+		contract.tokenCache = handlers
+		contract.tokenCacheExpiredAt = time.Now().Add(contract.tokenCacheDuration)
+		contract.tokenCacheLock.Unlock()
+	}
+	return append([]AptosTokenHandler{}, handlers...), nil
+}
+
+// fetchTokenHandlers get the handlers from contract by resouce
 // when api support call move public function, should not use resouce
-func (contract *aptosRedPacketContract) getTokenHandler(tokenAddress string) (tokenHandler, error) {
-	client, err := contract.chain.GetClient()
+func (contract *aptosRedPacketContract) fetchTokenHandlers(ctx context.Context) ([]AptosTokenHandler, error) {
+	client, err := contract.remoteClient()
 	if err != nil {
-		return tokenHandler{}, errors.New("get client failed")
+		return nil, err
 	}
-	resource, err := client.GetAccountResource(contract.address, contract.address+"::red_packet::GlobalConfig", 0)
+	resource, err := aptosCall(ctx, func() (*aptostypes.AccountResource, error) {
+		return client.GetAccountResource(contract.address, contract.address+"::red_packet::GlobalConfig", 0)
+	})
 	if err != nil {
-		return tokenHandler{}, errors.New("not found account resource")
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, aptosNetworkError(err)
 	}
-	handlers, _ := resource.Data["handlers"].([]interface{})
-	for _, handler := range handlers {
+	handlerList, _ := resource.Data["handlers"].([]interface{})
+	handlers := make([]AptosTokenHandler, 0, len(handlerList))
+	for _, handler := range handlerList {
 		handlerMap, _ := handler.(map[string]interface{})
 		coinType, _ := handlerMap["coin_type"].(string)
-		if coinType != tokenAddress {
-			continue
-		}
 		config, _ := handlerMap["config"].(map[string]interface{})
 		feePoint, _ := config["fee_point"].(float64)
-		handlerIndex, _ := strconv.ParseUint(handlerMap["handler_index"].(string), 10, 64)
-		return tokenHandler{
-			CoinType:     coinType,
-			HandlerIndex: handlerIndex,
-			FeePoint:     uint64(feePoint),
-		}, nil
+		basePrepaid, _ := config["base_prepaid"].(string)
+		handlerIndex, _ := strconv.ParseUint(fmt.Sprint(handlerMap["handler_index"]), 10, 64)
+		nextId, _ := strconv.ParseUint(fmt.Sprint(handlerMap["next_id"]), 10, 64)
+		escrowAddress, _ := handlerMap["escrow_address"].(string)
+		store, _ := handlerMap["store"].(map[string]interface{})
+		storeHandle, _ := store["handle"].(string)
+		handlers = append(handlers, AptosTokenHandler{
+			CoinType:      coinType,
+			HandlerIndex:  handlerIndex,
+			FeePoint:      uint64(feePoint),
+			BasePrepaid:   basePrepaid,
+			EscrowAddress: escrowAddress,
+			NextId:        nextId,
+			StoreHandle:   storeHandle,
+		})
 	}
-	return tokenHandler{}, errors.New("not found token handler")
+	return handlers, nil
 }
 
 func (contract *aptosRedPacketContract) FetchRedPacketCreationDetail(hash string) (*RedPacketDetail, error) {
-	client, err := contract.chain.GetClient()
+	return contract.FetchRedPacketCreationDetailWithContext(context.Background(), hash)
+}
+
+func (contract *aptosRedPacketContract) FetchRedPacketCreationDetailWithContext(ctx context.Context, hash string) (*RedPacketDetail, error) {
+	transaction, baseTransaction, coinInfo, err := contract.fetchRedPacketTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
-	transaction, err := client.GetTransactionByHash(hash)
+
+	redPacketDetail := &RedPacketDetail{
+		TransactionDetail: baseTransaction,
+		AmountName:        coinInfo.Name,
+		AmountDecimal:     int16(coinInfo.Decimals),
+		Abort:             transactionAbort(baseTransaction),
+	}
+
+	if len(transaction.Payload.Arguments) < 3 {
+		return redPacketDetail, newRedPacketDataError(fmt.Sprintf("invalid payload arguments, len %d", len(transaction.Payload.Arguments)))
+	}
+	baseTransaction.Amount = transaction.Payload.Arguments[2].(string)
+
+	redPacketAmount := "0"
+
+	eventData, err := contract.redPacketEventData(transaction, redPacketEventCreate)
 	if err != nil {
-		var restError *aptostypes.RestError
-		if errors.As(err, &restError) {
-			return nil, newRedPacketDataError(restError.Message)
+		return redPacketDetail, err
+	}
+	if eventData != nil {
+		var ok bool
+		redPacketAmount, ok = eventData["remain_balance"].(string)
+		if !ok {
+			return redPacketDetail, newRedPacketDataError("redpacket data remain_balance is not string")
 		}
+		packetId, ok := eventData["id"].(string)
+		if !ok {
+			return redPacketDetail, newRedPacketDataError("redpacket data id is not string")
+		}
+		redPacketDetail.PacketId, err = strconv.ParseInt(packetId, 10, 64)
+		if err != nil {
+			return redPacketDetail, newRedPacketDataError("redpacket data id is not u64")
+		}
+	}
+
+	redPacketDetail.RedPacketAmount = redPacketAmount
+	return redPacketDetail, nil
+}
+
+func (contract *aptosRedPacketContract) FetchRedPacketOpenDetail(hash string) (*RedPacketOpenDetail, error) {
+	return contract.FetchRedPacketOpenDetailWithContext(context.Background(), hash)
+}
+
+func (contract *aptosRedPacketContract) FetchRedPacketOpenDetailWithContext(ctx context.Context, hash string) (*RedPacketOpenDetail, error) {
+	transaction, baseTransaction, coinInfo, err := contract.fetchRedPacketTransaction(ctx, hash)
+	if err != nil {
 		return nil, err
 	}
-	baseTransaction, err := toBaseTransaction(transaction)
+	if transaction.Payload.Function != contract.address+"::red_packet::open" {
+		return nil, newRedPacketDataError("not open transaction")
+	}
+
+	openDetail := &RedPacketOpenDetail{
+		TransactionDetail: baseTransaction,
+		AmountName:        coinInfo.Name,
+		AmountDecimal:     int16(coinInfo.Decimals),
+		ChainName:         ChainTypeAptos,
+		Abort:             transactionAbort(baseTransaction),
+	}
+
+	// open(handler_index, id, lucky_accounts, balances)
+	args := transaction.Payload.Arguments
+	if len(args) < 4 {
+		return openDetail, newRedPacketDataError(fmt.Sprintf("invalid payload arguments, len %d", len(args)))
+	}
+	packetId, _ := args[1].(string)
+	openDetail.PacketId, err = strconv.ParseInt(packetId, 10, 64)
 	if err != nil {
-		return nil, newRedPacketDataError(err.Error())
+		return openDetail, newRedPacketDataError("open id is not u64")
+	}
+	addresses, _ := args[2].([]interface{})
+	amounts, _ := args[3].([]interface{})
+	if len(addresses) != len(amounts) {
+		return openDetail, newRedPacketDataError("the number of opened addresses is not the same as the amount")
 	}
+	openDetail.Addresses = make([]string, len(addresses))
+	openDetail.Amounts = make([]string, len(amounts))
+	for i := range addresses {
+		openDetail.Addresses[i], _ = addresses[i].(string)
+		openDetail.Amounts[i], _ = amounts[i].(string)
+	}
+	baseTransaction.Amount = sumAmounts(openDetail.Amounts)
 
-	if len(transaction.Payload.TypeArguments) == 0 {
-		return nil, newRedPacketDataError("invalid transaction type args")
+	eventData, err := contract.redPacketEventData(transaction, redPacketEventOpen)
+	if err != nil {
+		return openDetail, err
 	}
+	if eventData != nil {
+		openDetail.RemainCount, openDetail.RemainBalance, err = redPacketEventRemain(eventData)
+		if err != nil {
+			return openDetail, err
+		}
+	}
+	return openDetail, nil
+}
 
-	coinInfo, err := client.GetCoinInfo(transaction.Payload.TypeArguments[0])
+func (contract *aptosRedPacketContract) FetchRedPacketCloseDetail(hash string) (*RedPacketCloseDetail, error) {
+	return contract.FetchRedPacketCloseDetailWithContext(context.Background(), hash)
+}
+
+func (contract *aptosRedPacketContract) FetchRedPacketCloseDetailWithContext(ctx context.Context, hash string) (*RedPacketCloseDetail, error) {
+	transaction, baseTransaction, coinInfo, err := contract.fetchRedPacketTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
+	if transaction.Payload.Function != contract.address+"::red_packet::close" {
+		return nil, newRedPacketDataError("not close transaction")
+	}
 
-	redPacketDetail := &RedPacketDetail{
+	closeDetail := &RedPacketCloseDetail{
 		TransactionDetail: baseTransaction,
 		AmountName:        coinInfo.Name,
 		AmountDecimal:     int16(coinInfo.Decimals),
+		ChainName:         ChainTypeAptos,
+		RefundAmount:      "0",
+		Abort:             transactionAbort(baseTransaction),
 	}
 
-	if len(transaction.Payload.Arguments) < 3 {
-		return redPacketDetail, newRedPacketDataError(fmt.Sprintf("invalid payload arguments, len %d", len(transaction.Payload.Arguments)))
+	// close(handler_index, id)
+	args := transaction.Payload.Arguments
+	if len(args) < 2 {
+		return closeDetail, newRedPacketDataError(fmt.Sprintf("invalid payload arguments, len %d", len(args)))
 	}
-	baseTransaction.Amount = transaction.Payload.Arguments[2].(string)
+	packetId, _ := args[1].(string)
+	closeDetail.PacketId, err = strconv.ParseInt(packetId, 10, 64)
+	if err != nil {
+		return closeDetail, newRedPacketDataError("close id is not u64")
+	}
+	baseTransaction.Amount = closeDetail.RefundAmount
 
-	redPacketAmount := "0"
+	eventData, err := contract.redPacketEventData(transaction, redPacketEventClose)
+	if err != nil {
+		return closeDetail, err
+	}
+	if eventData == nil {
+		return closeDetail, nil
+	}
+	refund, ok := eventData["remain_balance"].(string)
+	if !ok {
+		return closeDetail, newRedPacketDataError("redpacket data remain_balance is not string")
+	}
+	closeDetail.RefundAmount = refund
+	baseTransaction.Amount = refund
+
+	// the refund is deposited to the creator
+	for _, event := range transaction.Events {
+		if event.Type != "0x1::coin::DepositEvent" || event.Guid == nil {
+			continue
+		}
+		eventData, _ := event.Data.(map[string]interface{})
+		if amount, _ := eventData["amount"].(string); amount == refund {
+			closeDetail.Creator = event.Guid.AccountAddress
+			break
+		}
+	}
+	return closeDetail, nil
+}
 
+// fetchRedPacketTransaction get the red packet transaction with coin info of it's coin type
+func (contract *aptosRedPacketContract) fetchRedPacketTransaction(ctx context.Context, hash string) (*aptostypes.Transaction, *base.TransactionDetail, aptostypes.CoinInfo, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, nil, aptostypes.CoinInfo{}, err
+	}
+	transaction, err := aptosCall(ctx, func() (*aptostypes.Transaction, error) {
+		return client.GetTransactionByHash(hash)
+	})
+	if err != nil {
+		var restError *aptostypes.RestError
+		if errors.As(err, &restError) && !IsRetryable(err) {
+			return nil, nil, aptostypes.CoinInfo{}, newRedPacketDataError(restError.Message)
+		}
+		return nil, nil, aptostypes.CoinInfo{}, err
+	}
+	baseTransaction, err := toBaseTransaction(transaction)
+	if err != nil {
+		return nil, nil, aptostypes.CoinInfo{}, newRedPacketDataError(err.Error())
+	}
+
+	if len(transaction.Payload.TypeArguments) == 0 {
+		return nil, nil, aptostypes.CoinInfo{}, newRedPacketDataError("invalid transaction type args")
+	}
+
+	coinInfo, err := aptosCall(ctx, func() (aptostypes.CoinInfo, error) {
+		return client.GetCoinInfo(transaction.Payload.TypeArguments[0])
+	})
+	if err != nil {
+		return nil, nil, aptostypes.CoinInfo{}, err
+	}
+	return transaction, baseTransaction, coinInfo, nil
+}
+
+// redPacketEventData find the data of RedPacketEvent, return nil if the transaction has no RedPacketEvent
+func (contract *aptosRedPacketContract) redPacketEventData(transaction *aptostypes.Transaction, eventType int) (map[string]interface{}, error) {
 	for _, event := range transaction.Events {
 		if event.Type != contract.address+"::red_packet::RedPacketEvent" {
 			continue
 		}
 		eventData, ok := event.Data.(map[string]interface{})
 		if !ok {
-			return redPacketDetail, newRedPacketDataError("redpacket event data is not map[string]interface{}")
+			return nil, newRedPacketDataError("redpacket event data is not map[string]interface{}")
 		}
-		eventType, ok := eventData["event_type"].(float64)
+		dataEventType, ok := eventData["event_type"].(float64)
 		if !ok {
-			return redPacketDetail, newRedPacketDataError("redpacket data eventType is not float64")
+			return nil, newRedPacketDataError("redpacket data eventType is not float64")
 		}
-		// 0 是 create event
-		if int(eventType) != 0 {
-			return redPacketDetail, newRedPacketDataError("not create event")
+		if int(dataEventType) != eventType {
+			return nil, newRedPacketDataError(fmt.Sprintf("not %s event", redPacketEventName(eventType)))
 		}
-		redPacketAmount, ok = eventData["remain_balance"].(string)
-		if !ok {
-			return redPacketDetail, newRedPacketDataError("redpacket data remain_balance is not string")
+		return eventData, nil
+	}
+	return nil, nil
+}
+
+func (contract *aptosRedPacketContract) PrepareCreate(owner string, rpa *RedPacketAction) (*RedPacketAction, error) {
+	return contract.PrepareCreateWithContext(context.Background(), owner, rpa)
+}
+
+// PrepareCreateWithContext the create action can be sent directly, there is no approve on aptos
+func (contract *aptosRedPacketContract) PrepareCreateWithContext(ctx context.Context, owner string, rpa *RedPacketAction) (*RedPacketAction, error) {
+	if rpa.Method != RPAMethodCreate || rpa.CreateParams == nil {
+		return nil, newInvalidParamsError("invalid create params")
+	}
+	return nil, nil
+}
+
+func (contract *aptosRedPacketContract) FetchRedPacketState(tokenAddress string, packetId int64, packetObjectId string) (*RedPacketState, error) {
+	return contract.FetchRedPacketStateWithContext(context.Background(), tokenAddress, packetId, packetObjectId)
+}
+
+func (contract *aptosRedPacketContract) FetchRedPacketStateWithContext(ctx context.Context, tokenAddress string, packetId int64, packetObjectId string) (*RedPacketState, error) {
+	handler, err := contract.getTokenHandler(ctx, tokenAddress)
+	if err != nil {
+		return nil, err
+	}
+	if handler.StoreHandle == "" {
+		return nil, newRedPacketDataError("not found red packet store")
+	}
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	info := struct {
+		RemainCoin  string `json:"remain_coin"`
+		RemainCount string `json:"remain_count"`
+	}{}
+	_, err = aptosCall(ctx, func() (struct{}, error) {
+		return struct{}{}, client.GetTableItem(&info, handler.StoreHandle, aptosclient.TableItemRequest{
+			KeyType:   "u64",
+			ValueType: contract.address + "::red_packet::RedPacketInfo",
+			Key:       strconv.FormatInt(packetId, 10),
+		}, "")
+	})
+	if err != nil {
+		var restError *aptostypes.RestError
+		if errors.As(err, &restError) && !IsRetryable(err) {
+			if restError.Code == http.StatusNotFound {
+				return nil, newRedPacketError(ErrorCodePacketNotFound, restError.Message)
+			}
+			return nil, newRedPacketDataError(restError.Message)
 		}
-		break
+		return nil, err
+	}
+	remainCount, err := strconv.ParseInt(info.RemainCount, 10, 64)
+	if err != nil {
+		return nil, newRedPacketDataError("red packet remain_count is not u64")
 	}
+	return &RedPacketState{
+		TokenAddress:  tokenAddress,
+		RemainCount:   remainCount,
+		RemainBalance: info.RemainCoin,
+		Valid:         remainCount > 0,
+	}, nil
+}
 
-	redPacketDetail.RedPacketAmount = redPacketAmount
-	return redPacketDetail, nil
+func (contract *aptosRedPacketContract) WaitForConfirmation(ctx context.Context, hash string, opts *ConfirmOptions) (*RedPacketConfirmation, error) {
+	return waitForConfirmation(ctx, contract, contract, hash, opts)
+}
+
+// transactionStatus the rest api return 404 before the transaction is received, and pending_transaction before executed
+func (contract *aptosRedPacketContract) transactionStatus(ctx context.Context, hash string) (*base.TransactionDetail, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	transaction, err := aptosCall(ctx, func() (*aptostypes.Transaction, error) {
+		return client.GetTransactionByHash(hash)
+	})
+	if err != nil {
+		var restError *aptostypes.RestError
+		if errors.As(err, &restError) && restError.Code == http.StatusNotFound {
+			return nil, errTransactionNotFound
+		}
+		return nil, err
+	}
+	if transaction.Type == aptostypes.TypePendingTransaction {
+		return &base.TransactionDetail{HashString: hash, Status: base.TransactionStatusPending}, nil
+	}
+	detail, err := toBaseTransaction(transaction)
+	if err != nil {
+		return nil, newRedPacketDataError(err.Error())
+	}
+	return detail, nil
 }
 
 func (contract *aptosRedPacketContract) SendTransaction(account base.Account, rpa *RedPacketAction) (string, error) {
-	payload, err := contract.createPayload(rpa)
+	return contract.SendTransactionWithContext(context.Background(), account, rpa)
+}
+
+func (contract *aptosRedPacketContract) SendTransactionWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (string, error) {
+	payload, err := contract.createPayload(ctx, rpa)
 	if err != nil {
 		return "", err
 	}
@@ -200,20 +596,334 @@ func (contract *aptosRedPacketContract) SendTransaction(account base.Account, rp
 	if err != nil {
 		return "", err
 	}
-	return contract.chain.SubmitTransactionPayloadBCS(account, data)
+	// wallet-SDK submit does not accept context, give up before the transaction is broadcast
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+	hash, err := contract.chain.SubmitTransactionPayloadBCS(account, data)
+	if err != nil {
+		return "", aptosNetworkError(err)
+	}
+	return hash, nil
 }
 
-func (contract *aptosRedPacketContract) createPayload(rpa *RedPacketAction) (txbuilder.TransactionPayload, error) {
+func (contract *aptosRedPacketContract) BuildUnsignedTransaction(sender string, rpa *RedPacketAction) (*UnsignedTransaction, error) {
+	return contract.BuildUnsignedTransactionWithContext(context.Background(), sender, rpa)
+}
+
+// BuildUnsignedTransactionWithContext build the RawTransaction with the sequence number of sender,
+// sign the SigningMessage with ed25519 and submit the BCS SignedTransaction.
+// The simulation needs the public key, which is taken from the last transaction of sender, the max gas amount
+// is the simulated gas used with the margin. The sender without transaction or with the rotated or multi-sig key
+// can't be simulated, the max gas amount is the default aptosMaxGasAmount.
+func (contract *aptosRedPacketContract) BuildUnsignedTransactionWithContext(ctx context.Context, sender string, rpa *RedPacketAction) (*UnsignedTransaction, error) {
+	rawTxn, account, err := contract.buildRawTransaction(ctx, sender, rpa)
+	if err != nil {
+		return nil, err
+	}
+	gasFee := rawTxn.GasUnitPrice * rawTxn.MaxGasAmount
+	publicKey, err := contract.senderPublicKey(ctx, sender, account)
+	if err != nil {
+		return nil, err
+	}
+	if publicKey != nil {
+		gasUsed, err := contract.simulateGasUsed(ctx, publicKey, rawTxn)
+		if err != nil {
+			return nil, err
+		}
+		rawTxn.MaxGasAmount = base.Min(gasUsed*aptosGasMarginPercent/100, uint64(aptosMaxGasAmount))
+		gasFee = rawTxn.GasUnitPrice * gasUsed
+	}
+	data, err := lcs.Marshal(rawTxn)
+	if err != nil {
+		return nil, err
+	}
+	signingMessage, err := rawTxn.GetSigningMessage()
+	if err != nil {
+		return nil, err
+	}
+	return &UnsignedTransaction{
+		ChainName:      ChainTypeAptos,
+		Sender:         rawTxn.Sender.ToString(),
+		Data:           data,
+		SigningMessage: signingMessage,
+		Nonce:          rawTxn.SequenceNumber,
+		GasLimit:       rawTxn.MaxGasAmount,
+		GasPrice:       strconv.FormatUint(rawTxn.GasUnitPrice, 10),
+		ChainId:        strconv.Itoa(int(rawTxn.ChainId)),
+		Expiration:     int64(rawTxn.ExpirationTimestampSecs),
+		EstimateGasFee: strconv.FormatUint(gasFee, 10),
+	}, nil
+}
+
+// senderPublicKey the ed25519 public key of sender in it's last transaction, it's nil if the sender has no transaction,
+// or the key doesn't match the authentication key, e.g. it's rotated or multi-sig.
+func (contract *aptosRedPacketContract) senderPublicKey(ctx context.Context, sender string, account *aptostypes.AccountCoreData) (ed25519.PublicKey, error) {
+	if account.SequenceNumber == 0 {
+		return nil, nil
+	}
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := aptosCall(ctx, func() ([]aptostypes.Transaction, error) {
+		return client.GetAccountTransactions(sender, account.SequenceNumber-1, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 || transactions[0].Signature == nil || transactions[0].Signature.Type != "ed25519_signature" {
+		return nil, nil
+	}
+	publicKey, err := hex.DecodeString(strings.TrimPrefix(transactions[0].Signature.PublicKey, "0x"))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, nil
+	}
+	authKey := sha3.Sum256(append(publicKey, 0x00))
+	if !strings.EqualFold(strings.TrimPrefix(account.AuthenticationKey, "0x"), hex.EncodeToString(authKey[:])) {
+		return nil, nil
+	}
+	return publicKey, nil
+}
+
+// simulateGasUsed simulate the raw transaction signed with the zero key, the failed simulation is returned as the abort
+func (contract *aptosRedPacketContract) simulateGasUsed(ctx context.Context, publicKey ed25519.PublicKey, rawTxn *txbuilder.RawTransaction) (uint64, error) {
+	signedTxn, err := txbuilder.GenerateBCSSimulation(publicKey, rawTxn)
+	if err != nil {
+		return 0, err
+	}
+	client, err := contract.remoteClient()
+	if err != nil {
+		return 0, err
+	}
+	transactions, err := aptosCall(ctx, func() ([]*aptostypes.Transaction, error) {
+		return client.SimulateSignedBCSTransaction(signedTxn)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(transactions) == 0 {
+		return 0, newRedPacketDataError("empty simulate result")
+	}
+	if !transactions[0].Success {
+		if abort := DecodeContractAbort(transactions[0].VmStatus); abort != nil {
+			return 0, abort
+		}
+		return 0, newRedPacketError(ErrorCodeContractAbort, transactions[0].VmStatus)
+	}
+	return transactions[0].GasUsed, nil
+}
+
+// buildRawTransaction build the RawTransaction of the action with the sequence number of sender
+func (contract *aptosRedPacketContract) buildRawTransaction(ctx context.Context, sender string, rpa *RedPacketAction) (*txbuilder.RawTransaction, *aptostypes.AccountCoreData, error) {
+	senderAddress, err := txbuilder.NewAccountAddressFromHex(sender)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := contract.createPayload(ctx, rpa)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := aptosCall(ctx, func() (*aptostypes.AccountCoreData, error) {
+		return client.GetAccount(sender)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	gasPrice, err := aptosCall(ctx, func() (uint64, error) {
+		return client.EstimateGasPrice()
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expiration := time.Now().Add(aptosTransactionExpiration).Unix()
+	return &txbuilder.RawTransaction{
+		Sender:                  *senderAddress,
+		SequenceNumber:          account.SequenceNumber,
+		Payload:                 payload,
+		MaxGasAmount:            aptosMaxGasAmount,
+		GasUnitPrice:            gasPrice,
+		ExpirationTimestampSecs: uint64(expiration),
+		ChainId:                 uint8(client.ChainId()),
+	}, account, nil
+}
+
+func (contract *aptosRedPacketContract) SimulateAction(account base.Account, rpa *RedPacketAction) (*SimulateResult, error) {
+	return contract.SimulateActionWithContext(context.Background(), account, rpa)
+}
+
+// SimulateActionWithContext simulate the transaction signed with the zero key, the balance changes are summed
+// from the coin withdraw and deposit events, which are all the coin of the action, the gas fee isn't included.
+func (contract *aptosRedPacketContract) SimulateActionWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*SimulateResult, error) {
+	rawTxn, _, err := contract.buildRawTransaction(ctx, account.Address(), rpa)
+	if err != nil {
+		return nil, err
+	}
+	signedTxn, err := txbuilder.GenerateBCSSimulation(account.PublicKey(), rawTxn)
+	if err != nil {
+		return nil, err
+	}
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := aptosCall(ctx, func() ([]*aptostypes.Transaction, error) {
+		return client.SimulateSignedBCSTransaction(signedTxn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return nil, newRedPacketDataError("empty simulate result")
+	}
+	transaction := transactions[0]
+
+	result := &SimulateResult{
+		Success:        transaction.Success,
+		EstimateGasFee: strconv.FormatUint(transaction.GasUnitPrice*transaction.GasUsed, 10),
+		BalanceChanges: aptosBalanceChanges(transaction.Events, rpa.TokenAddress()),
+		Events:         []*RedPacketEvent{},
+	}
+	if !transaction.Success {
+		result.FailureMessage = transaction.VmStatus
+		result.Abort = DecodeContractAbort(transaction.VmStatus)
+		return result, nil
+	}
+	for _, event := range transaction.Events {
+		if event.Type != contract.address+"::red_packet::RedPacketEvent" {
+			continue
+		}
+		eventData, ok := event.Data.(map[string]interface{})
+		if !ok {
+			return nil, newRedPacketDataError("redpacket event data is not map[string]interface{}")
+		}
+		redPacketEvent, err := newRedPacketEvent(eventData)
+		if err != nil {
+			return nil, err
+		}
+		result.Events = append(result.Events, redPacketEvent)
+	}
+	return result, nil
+}
+
+func (contract *aptosRedPacketContract) QuoteCreate(account base.Account, rpa *RedPacketAction) (*CreateQuote, error) {
+	return contract.QuoteCreateWithContext(context.Background(), account, rpa)
+}
+
+// QuoteCreateWithContext the service fee is charged in the red packet coin
+func (contract *aptosRedPacketContract) QuoteCreateWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*CreateQuote, error) {
+	return quoteCreate(ctx, contract, account, rpa)
+}
+
+func (contract *aptosRedPacketContract) unestimatedGasFee(ctx context.Context) (*big.Int, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	gasPrice, err := aptosCall(ctx, func() (uint64, error) {
+		return client.EstimateGasPrice()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(0).Mul(big.NewInt(0).SetUint64(gasPrice), big.NewInt(aptosMaxGasAmount)), nil
+}
+
+func (contract *aptosRedPacketContract) quoteTokens(rpa *RedPacketAction) (string, string) {
+	return aptosCoinType, rpa.CreateParams.TokenAddress
+}
+
+func (contract *aptosRedPacketContract) sameToken(a, b string) bool {
+	return a == b
+}
+
+func (contract *aptosRedPacketContract) balanceOf(ctx context.Context, owner, token string) (*big.Int, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	return aptosCall(ctx, func() (*big.Int, error) {
+		return client.BalanceOf(owner, token)
+	})
+}
+
+// aptosBalanceChanges sum the amount of 0x1::coin WithdrawEvent and DepositEvent by the account
+func aptosBalanceChanges(events []aptostypes.Event, tokenAddress string) []*BalanceChange {
+	changes := []*BalanceChange{}
+	amounts := make(map[string]*big.Int)
+	for _, event := range events {
+		if event.Guid == nil {
+			continue
+		}
+		sign := 0
+		switch event.Type {
+		case "0x1::coin::WithdrawEvent":
+			sign = -1
+		case "0x1::coin::DepositEvent":
+			sign = 1
+		default:
+			continue
+		}
+		eventData, _ := event.Data.(map[string]interface{})
+		amount, ok := big.NewInt(0).SetString(fmt.Sprint(eventData["amount"]), 10)
+		if !ok {
+			continue
+		}
+		address := event.Guid.AccountAddress
+		if _, exist := amounts[address]; !exist {
+			amounts[address] = big.NewInt(0)
+			changes = append(changes, &BalanceChange{Address: address, TokenAddress: tokenAddress})
+		}
+		if sign < 0 {
+			amount.Neg(amount)
+		}
+		amounts[address].Add(amounts[address], amount)
+	}
+	for _, change := range changes {
+		change.Amount = amounts[change.Address].String()
+	}
+	return changes
+}
+
+func (contract *aptosRedPacketContract) SubmitSignedTransaction(signedTx *SignedTransaction) (string, error) {
+	return contract.SubmitSignedTransactionWithContext(context.Background(), signedTx)
+}
+
+func (contract *aptosRedPacketContract) SubmitSignedTransactionWithContext(ctx context.Context, signedTx *SignedTransaction) (string, error) {
+	if signedTx == nil || len(signedTx.Data) == 0 {
+		return "", newInvalidParamsError("invalid signed transaction")
+	}
+	client, err := contract.remoteClient()
+	if err != nil {
+		return "", err
+	}
+	// go-aptos submit does not accept context, give up before the transaction is broadcast
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+	transaction, err := client.SubmitSignedBCSTransaction(signedTx.Data)
+	if err != nil {
+		return "", aptosNetworkError(err)
+	}
+	return transaction.Hash, nil
+}
+
+func (contract *aptosRedPacketContract) createPayload(ctx context.Context, rpa *RedPacketAction) (txbuilder.TransactionPayload, error) {
 	switch rpa.Method {
 	case RPAMethodCreate:
 		if nil == rpa.CreateParams {
-			return nil, fmt.Errorf("create params is nil")
+			return nil, newInvalidParamsError("create params is nil")
 		}
 		amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("amount params is not uint64")
+			return nil, newInvalidParamsError("amount params is not uint64")
 		}
-		handler, err := contract.getTokenHandler(rpa.CreateParams.TokenAddress)
+		handler, err := contract.getTokenHandler(ctx, rpa.CreateParams.TokenAddress)
 		if err != nil {
 			return nil, err
 		}
@@ -231,10 +941,10 @@ func (contract *aptosRedPacketContract) createPayload(rpa *RedPacketAction) (txb
 		)
 	case RPAMethodOpen:
 		if nil == rpa.OpenParams {
-			return nil, fmt.Errorf("open params is nil")
+			return nil, newInvalidParamsError("open params is nil")
 		}
 		if rpa.OpenParams.TokenAddress == "" {
-			return nil, fmt.Errorf("params.TokenAddress must not empty")
+			return nil, newInvalidParamsError("params.TokenAddress must not empty")
 		}
 		amountsArr := make([]any, len(rpa.OpenParams.Amounts))
 		addressList := make([]any, len(rpa.OpenParams.Addresses))
@@ -242,15 +952,15 @@ func (contract *aptosRedPacketContract) createPayload(rpa *RedPacketAction) (txb
 		for i, a := range rpa.OpenParams.Amounts {
 			amountsArr[i], err = strconv.ParseUint(a, 10, 64)
 			if err != nil {
-				return nil, fmt.Errorf("open amounts error")
+				return nil, newInvalidParamsError("open amounts error")
 			}
 			paddress, e := txbuilder.NewAccountAddressFromHex(rpa.OpenParams.Addresses[i])
 			if e != nil {
-				return nil, fmt.Errorf("open amounts error")
+				return nil, newInvalidParamsError("open amounts error")
 			}
 			addressList[i] = *paddress
 		}
-		handler, err := contract.getTokenHandler(rpa.OpenParams.TokenAddress)
+		handler, err := contract.getTokenHandler(ctx, rpa.OpenParams.TokenAddress)
 		if err != nil {
 			return nil, err
 		}
@@ -268,12 +978,12 @@ func (contract *aptosRedPacketContract) createPayload(rpa *RedPacketAction) (txb
 		)
 	case RPAMethodClose:
 		if nil == rpa.CloseParams {
-			return nil, fmt.Errorf("close params is nil")
+			return nil, newInvalidParamsError("close params is nil")
 		}
 		if rpa.CloseParams.TokenAddress == "" {
-			return nil, fmt.Errorf("params.TokenAddress must not empty")
+			return nil, newInvalidParamsError("params.TokenAddress must not empty")
 		}
-		handler, err := contract.getTokenHandler(rpa.CloseParams.TokenAddress)
+		handler, err := contract.getTokenHandler(ctx, rpa.CloseParams.TokenAddress)
 		if err != nil {
 			return nil, err
 		}
@@ -288,10 +998,16 @@ func (contract *aptosRedPacketContract) createPayload(rpa *RedPacketAction) (txb
 			},
 		)
 	default:
-		return nil, fmt.Errorf("unsopported red packet method %s", rpa.Method)
+		return nil, newInvalidParamsError("unsopported red packet method %s", rpa.Method)
 	}
 }
 
+// CalcTotal the total amount to send when creating a red packet with amount on aptos/sui,
+// the contract deduct total / 10000 * feePoint as the fee and the rest is the red packet amount.
+func CalcTotal(amount uint64, feePoint uint64) uint64 {
+	return calcTotal(amount, feePoint)
+}
+
 // calcTotal caculate totalAmount should send, when user want create a red packet with amount
 func calcTotal(amount uint64, feePoint uint64) uint64 {
 	if feePoint == 0 {
@@ -322,7 +1038,7 @@ func calcTotal(amount uint64, feePoint uint64) uint64 {
 func toBaseTransaction(transaction *aptostypes.Transaction) (*base.TransactionDetail, error) {
 	if transaction.Type != aptostypes.TypeUserTransaction ||
 		transaction.Payload.Type != aptostypes.EntryFunctionPayload {
-		return nil, errors.New("invalid transfer transaction")
+		return nil, newRedPacketDataError("invalid transfer transaction")
 	}
 
 	detail := &base.TransactionDetail{
@@ -352,3 +1068,46 @@ func toBaseTransaction(transaction *aptostypes.Transaction) (*base.TransactionDe
 
 	return detail, nil
 }
+
+// aptosCall run the rest client call and return as soon as ctx is done,
+// go-aptos client doesn't support context so the abandoned request is left to finish in background.
+// Only the read calls use it, the submit must not be abandoned while it may still broadcast.
+func aptosCall[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		value, err := call()
+		ch <- result{value, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case r := <-ch:
+		return r.value, aptosNetworkError(r.err)
+	}
+}
+
+// remoteClient get the rest client of the chain, the dial error is the network error
+func (contract *aptosRedPacketContract) remoteClient() (*aptosclient.RestClient, error) {
+	client, err := contract.chain.GetClient()
+	if err != nil {
+		return nil, aptosNetworkError(err)
+	}
+	return client, nil
+}
+
+// aptosNetworkError the rate limit and server errors of the node are retryable as the transport errors
+func aptosNetworkError(err error) error {
+	var restError *aptostypes.RestError
+	if errors.As(err, &restError) && (restError.Code == http.StatusTooManyRequests || restError.Code >= http.StatusInternalServerError) {
+		return &RedPacketError{Code: ErrorCodeNetwork, Message: "network error", Err: err}
+	}
+	return networkError(err)
+}
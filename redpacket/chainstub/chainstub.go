@@ -0,0 +1,250 @@
+// Package chainstub provide local http stub servers of the chain rpc, the wallet-SDK chains can be created with
+// the server url to test the red packet contracts without network. The servers record the received requests
+// and return the canned responses, e.g. the golden fixtures of the transactions and events.
+//   - JSONRPCServer for eth and sui json-rpc
+//   - RESTServer for aptos rest api
+package chainstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+)
+
+// RPCError the json-rpc error returned by the handler
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"` // e.g. the eth revert data
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// JSONRPCHandler handle the params of the request, the returned error is converted to the json-rpc error,
+// use *RPCError to set the code.
+type JSONRPCHandler func(params []json.RawMessage) (interface{}, error)
+
+// JSONRPCRequest the recorded json-rpc request
+type JSONRPCRequest struct {
+	Method string
+	Params []json.RawMessage
+}
+
+type jsonrpcMessage struct {
+	Version string            `json:"jsonrpc"`
+	Id      json.RawMessage   `json:"id,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Params  []json.RawMessage `json:"params,omitempty"`
+	Result  interface{}       `json:"result,omitempty"`
+	Error   *RPCError         `json:"error,omitempty"`
+}
+
+// JSONRPCServer the json-rpc stub server, the batch request is supported
+type JSONRPCServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]JSONRPCHandler
+	requests []JSONRPCRequest
+}
+
+// NewJSONRPCServer start the server, call Close after the test
+func NewJSONRPCServer() *JSONRPCServer {
+	s := &JSONRPCServer{handlers: make(map[string]JSONRPCHandler)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *JSONRPCServer) Handle(method string, handler JSONRPCHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// HandleResult return the result for the method, use json.RawMessage for the raw json
+func (s *JSONRPCServer) HandleResult(method string, result interface{}) {
+	s.Handle(method, func(params []json.RawMessage) (interface{}, error) {
+		return result, nil
+	})
+}
+
+// HandleFixture return the json file content as the result for the method
+func (s *JSONRPCServer) HandleFixture(method string, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	s.HandleResult(method, json.RawMessage(data))
+	return nil
+}
+
+// Requests return the recorded requests of the method, empty method return all requests
+func (s *JSONRPCServer) Requests(method string) []JSONRPCRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]JSONRPCRequest, 0, len(s.requests))
+	for _, request := range s.requests {
+		if method == "" || request.Method == method {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+func (s *JSONRPCServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var batch []jsonrpcMessage
+	if err := json.Unmarshal(body, &batch); err == nil {
+		responses := make([]jsonrpcMessage, len(batch))
+		for i, msg := range batch {
+			responses[i] = s.call(msg)
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+		return
+	}
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		_ = json.NewEncoder(w).Encode(jsonrpcMessage{
+			Version: "2.0",
+			Id:      json.RawMessage("null"),
+			Error:   &RPCError{Code: -32700, Message: err.Error()},
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(s.call(msg))
+}
+
+func (s *JSONRPCServer) call(msg jsonrpcMessage) jsonrpcMessage {
+	s.mu.Lock()
+	s.requests = append(s.requests, JSONRPCRequest{Method: msg.Method, Params: msg.Params})
+	handler, ok := s.handlers[msg.Method]
+	s.mu.Unlock()
+
+	response := jsonrpcMessage{Version: "2.0", Id: msg.Id}
+	if !ok {
+		response.Error = &RPCError{Code: -32601, Message: fmt.Sprintf("the method %s does not exist/is not available", msg.Method)}
+		return response
+	}
+	result, err := handler(msg.Params)
+	if err != nil {
+		rpcError, ok := err.(*RPCError)
+		if !ok {
+			rpcError = &RPCError{Code: -32000, Message: err.Error()}
+		}
+		response.Error = rpcError
+		return response
+	}
+	if result == nil {
+		// the result must be present in the success response
+		result = json.RawMessage("null")
+	}
+	response.Result = result
+	return response
+}
+
+// RESTHandler handle the request body, return the status code and the response body,
+// the body is encoded as json unless it's []byte.
+type RESTHandler func(body []byte) (int, interface{})
+
+// RESTRequest the recorded rest request
+type RESTRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   []byte
+}
+
+// RESTServer the rest stub server, the route is matched by the http method and the unescaped path
+type RESTServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]RESTHandler
+	requests []RESTRequest
+}
+
+// NewRESTServer start the server, call Close after the test
+func NewRESTServer() *RESTServer {
+	s := &RESTServer{handlers: make(map[string]RESTHandler)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *RESTServer) Handle(method, path string, handler RESTHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method+" "+path] = handler
+}
+
+// HandleResult return the status and body for the route, use json.RawMessage or []byte for the raw body
+func (s *RESTServer) HandleResult(method, path string, status int, body interface{}) {
+	s.Handle(method, path, func([]byte) (int, interface{}) {
+		return status, body
+	})
+}
+
+// HandleFixture return the json file content with status 200 for the route
+func (s *RESTServer) HandleFixture(method, path string, fixture string) error {
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		return err
+	}
+	s.HandleResult(method, path, http.StatusOK, json.RawMessage(data))
+	return nil
+}
+
+// Requests return the recorded requests of the path, empty path return all requests
+func (s *RESTServer) Requests(path string) []RESTRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]RESTRequest, 0, len(s.requests))
+	for _, request := range s.requests {
+		if path == "" || request.Path == path {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+func (s *RESTServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, RESTRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Body: body})
+	handler, ok := s.handlers[r.Method+" "+r.URL.Path]
+	s.mu.Unlock()
+
+	status, response := http.StatusNotFound, interface{}(map[string]interface{}{
+		"message":    fmt.Sprintf("%s %s not found", r.Method, r.URL.Path),
+		"error_code": "not_found",
+	})
+	if ok {
+		status, response = handler(body)
+	}
+	data, isBytes := response.([]byte)
+	if !isBytes {
+		data, err = json.Marshal(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
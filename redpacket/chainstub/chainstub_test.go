@@ -0,0 +1,63 @@
+package chainstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func postJSON(t *testing.T, url string, body string, v interface{}) {
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(v))
+}
+
+func TestJSONRPCServer(t *testing.T) {
+	server := NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+	server.Handle("eth_getBalance", func(params []json.RawMessage) (interface{}, error) {
+		return nil, errors.New("header not found")
+	})
+
+	var response map[string]interface{}
+	postJSON(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`, &response)
+	require.Equal(t, "0x1", response["result"])
+	require.Equal(t, float64(1), response["id"])
+
+	var batch []map[string]interface{}
+	postJSON(t, server.URL, `[{"jsonrpc":"2.0","id":2,"method":"eth_getBalance","params":["0x1","latest"]},{"jsonrpc":"2.0","id":3,"method":"eth_unknown"}]`, &batch)
+	require.Len(t, batch, 2)
+	require.Equal(t, "header not found", batch[0]["error"].(map[string]interface{})["message"])
+	require.Equal(t, float64(-32601), batch[1]["error"].(map[string]interface{})["code"])
+
+	requests := server.Requests("eth_getBalance")
+	require.Len(t, requests, 1)
+	require.Equal(t, `"latest"`, string(requests[0].Params[1]))
+	require.Len(t, server.Requests(""), 3)
+}
+
+func TestRESTServer(t *testing.T) {
+	server := NewRESTServer()
+	defer server.Close()
+	server.HandleResult(http.MethodGet, "/v1", http.StatusOK, map[string]interface{}{"chain_id": 1})
+
+	resp, err := http.Get(server.URL + "/v1?ledger_version=1")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/v1/transactions/by_hash/0x1")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	requests := server.Requests("/v1")
+	require.Len(t, requests, 1)
+	require.Equal(t, "ledger_version=1", requests[0].Query)
+}
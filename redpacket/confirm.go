@@ -0,0 +1,142 @@
+package redpacket
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+)
+
+const (
+	defaultConfirmPollInterval    = time.Second
+	defaultConfirmMaxPollInterval = 10 * time.Second
+	defaultConfirmDroppedAfter    = 2 * time.Minute
+)
+
+// errTransactionNotFound the transaction is not found on chain yet, it may be still in the mempool or dropped
+var errTransactionNotFound = errors.New("transaction not found")
+
+// ConfirmOptions the options of WaitForConfirmation, nil or zero fields use the default values
+type ConfirmOptions struct {
+	// Method the action method of the transaction (create/open/close) to decode the result,
+	// empty means only wait for the transaction status
+	Method string
+	// PollInterval the first poll interval, doubled after each poll, default 1 second
+	PollInterval time.Duration
+	// MaxPollInterval the max poll interval of the backoff, default 10 seconds
+	MaxPollInterval time.Duration
+	// DroppedAfter the transaction is treated as dropped when it's not found for the duration, default 2 minutes
+	DroppedAfter time.Duration
+	// Timeout the max duration to wait, 0 means wait until the context is done
+	Timeout time.Duration
+}
+
+func (opts *ConfirmOptions) withDefaults() ConfirmOptions {
+	o := ConfirmOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaultConfirmPollInterval
+	}
+	if o.MaxPollInterval <= 0 {
+		o.MaxPollInterval = defaultConfirmMaxPollInterval
+	}
+	if o.MaxPollInterval < o.PollInterval {
+		o.MaxPollInterval = o.PollInterval
+	}
+	if o.DroppedAfter <= 0 {
+		o.DroppedAfter = defaultConfirmDroppedAfter
+	}
+	return o
+}
+
+// RedPacketConfirmation the result of the confirmed transaction, only the detail of the Method is set
+type RedPacketConfirmation struct {
+	Method      string
+	Transaction *base.TransactionDetail
+
+	Create *RedPacketDetail      // the created packet id (aptos/eth) or packet object id (sui)
+	Open   *RedPacketOpenDetail  // the opened addresses and amounts, and the remain of the packet
+	Close  *RedPacketCloseDetail // the refund of the closed packet
+}
+
+// transactionStatusFetcher get the status of the transaction, return errTransactionNotFound if the chain
+// doesn't know the transaction, and the pending status if the transaction is not executed yet.
+type transactionStatusFetcher interface {
+	transactionStatus(ctx context.Context, hash string) (*base.TransactionDetail, error)
+}
+
+// waitForConfirmation wait for the transaction and decode the red packet result by the method
+func waitForConfirmation(ctx context.Context, contract RedPacketContractCtx, fetcher transactionStatusFetcher, hash string, opts *ConfirmOptions) (*RedPacketConfirmation, error) {
+	o := opts.withDefaults()
+	detail, err := waitTransaction(ctx, fetcher, hash, o)
+	if err != nil {
+		return nil, err
+	}
+	confirmation := &RedPacketConfirmation{
+		Method:      o.Method,
+		Transaction: detail,
+	}
+	if detail.Status == base.TransactionStatusFailure {
+		return confirmation, &TransactionRevertedError{
+			Hash:   hash,
+			Reason: detail.FailureMessage,
+			Abort:  DecodeContractAbort(detail.FailureMessage),
+		}
+	}
+	switch o.Method {
+	case RPAMethodCreate:
+		confirmation.Create, err = contract.FetchRedPacketCreationDetailWithContext(ctx, hash)
+	case RPAMethodOpen:
+		confirmation.Open, err = contract.FetchRedPacketOpenDetailWithContext(ctx, hash)
+	case RPAMethodClose:
+		confirmation.Close, err = contract.FetchRedPacketCloseDetailWithContext(ctx, hash)
+	}
+	return confirmation, err
+}
+
+// waitTransaction poll the transaction status with backoff until it's success or failure,
+// the rpc errors are retried until timeout, the last error is kept in the TransactionTimeoutError.
+func waitTransaction(ctx context.Context, fetcher transactionStatusFetcher, hash string, o ConfirmOptions) (*base.TransactionDetail, error) {
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	interval := o.PollInterval
+	lastSeen := time.Now()
+	var lastErr error
+	for {
+		detail, err := fetcher.transactionStatus(ctx, hash)
+		switch {
+		case err == nil:
+			if detail.Status != base.TransactionStatusPending {
+				return detail, nil
+			}
+			lastSeen = time.Now()
+		case errors.Is(err, errTransactionNotFound):
+			if time.Since(lastSeen) >= o.DroppedAfter {
+				return nil, &TransactionDroppedError{Hash: hash}
+			}
+		default:
+			lastErr = err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil, ctx.Err()
+			}
+			return nil, &TransactionTimeoutError{Hash: hash, Err: lastErr}
+		case <-timer.C:
+		}
+		interval *= 2
+		if interval > o.MaxPollInterval {
+			interval = o.MaxPollInterval
+		}
+	}
+}
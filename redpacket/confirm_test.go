@@ -0,0 +1,86 @@
+package redpacket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStatusFetcher struct {
+	results []func() (*base.TransactionDetail, error)
+	calls   int
+}
+
+func (f *fakeStatusFetcher) transactionStatus(ctx context.Context, hash string) (*base.TransactionDetail, error) {
+	index := f.calls
+	if index >= len(f.results) {
+		index = len(f.results) - 1
+	}
+	f.calls++
+	return f.results[index]()
+}
+
+func statusResult(status base.TransactionStatus) func() (*base.TransactionDetail, error) {
+	return func() (*base.TransactionDetail, error) {
+		return &base.TransactionDetail{Status: status, FailureMessage: "abort"}, nil
+	}
+}
+
+func errorResult(err error) func() (*base.TransactionDetail, error) {
+	return func() (*base.TransactionDetail, error) {
+		return nil, err
+	}
+}
+
+func TestWaitTransaction(t *testing.T) {
+	opts := (&ConfirmOptions{PollInterval: time.Millisecond, DroppedAfter: 20 * time.Millisecond}).withDefaults()
+
+	t.Run("success after not found and pending", func(t *testing.T) {
+		fetcher := &fakeStatusFetcher{results: []func() (*base.TransactionDetail, error){
+			errorResult(errTransactionNotFound),
+			errorResult(errors.New("network error")),
+			statusResult(base.TransactionStatusPending),
+			statusResult(base.TransactionStatusSuccess),
+		}}
+		detail, err := waitTransaction(context.Background(), fetcher, "0x1", opts)
+		require.Nil(t, err)
+		require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+		require.Equal(t, 4, fetcher.calls)
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		fetcher := &fakeStatusFetcher{results: []func() (*base.TransactionDetail, error){
+			errorResult(errTransactionNotFound),
+		}}
+		_, err := waitTransaction(context.Background(), fetcher, "0x1", opts)
+		var droppedErr *TransactionDroppedError
+		require.True(t, errors.As(err, &droppedErr))
+	})
+
+	t.Run("timeout with last error", func(t *testing.T) {
+		fetcher := &fakeStatusFetcher{results: []func() (*base.TransactionDetail, error){
+			errorResult(errors.New("network error")),
+		}}
+		timeoutOpts := opts
+		timeoutOpts.Timeout = 10 * time.Millisecond
+		_, err := waitTransaction(context.Background(), fetcher, "0x1", timeoutOpts)
+		var timeoutErr *TransactionTimeoutError
+		require.True(t, errors.As(err, &timeoutErr))
+		require.EqualError(t, timeoutErr.Err, "network error")
+	})
+
+	t.Run("reverted", func(t *testing.T) {
+		fetcher := &fakeStatusFetcher{results: []func() (*base.TransactionDetail, error){
+			statusResult(base.TransactionStatusFailure),
+		}}
+		confirmation, err := waitForConfirmation(context.Background(), nil, fetcher, "0x1", &ConfirmOptions{Method: RPAMethodOpen})
+		var revertedErr *TransactionRevertedError
+		require.True(t, errors.As(err, &revertedErr))
+		require.Equal(t, "abort", revertedErr.Reason)
+		require.Nil(t, confirmation.Open)
+	})
+}
@@ -0,0 +1,238 @@
+// Package distribution split the red packet amount into the shares that used by open action.
+// All amounts are decimal strings of the smallest unit and calculated with big.Int,
+// so it can be used by uint256 eth amounts.
+package distribution
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	ModeEqual  = "equal"  // 平均分配，除不尽的部分依次分给前面的红包
+	ModeLucky  = "lucky"  // 拼手气红包，二倍均值法
+	ModeFixed  = "fixed"  // 按照给定的金额列表分配
+	ModeSeeded = "seeded" // 可验证的拼手气红包，随机数由 seed 确定
+)
+
+// Split split total into count shares by mode, every share is not less than min,
+// and the sum of shares is equal to total. min is 1 if it's empty.
+// ModeFixed need the amount list and ModeSeeded need the seed, use SplitFixed and SplitSeeded instead.
+func Split(mode string, total string, count int, min string) ([]string, error) {
+	switch mode {
+	case ModeEqual:
+		return SplitEqual(total, count, min)
+	case ModeLucky:
+		return SplitLucky(total, count, min)
+	case ModeFixed:
+		return nil, errors.New("fixed mode need amounts, use SplitFixed")
+	case ModeSeeded:
+		return nil, errors.New("seeded mode need seed, use SplitSeeded")
+	default:
+		return nil, fmt.Errorf("unsupported split mode %s", mode)
+	}
+}
+
+// SplitEqual split total into count equal shares, the remainder is given to the first shares one by one.
+func SplitEqual(total string, count int, min string) ([]string, error) {
+	totalInt, minInt, err := parseParams(total, count, min)
+	if err != nil {
+		return nil, err
+	}
+	share, remainder := big.NewInt(0).QuoRem(totalInt, big.NewInt(int64(count)), big.NewInt(0))
+	rest := remainder.Int64()
+	shares := make([]*big.Int, count)
+	for i := range shares {
+		shares[i] = big.NewInt(0).Set(share)
+		if int64(i) < rest {
+			shares[i].Add(shares[i], big.NewInt(1))
+		}
+	}
+	if shares[count-1].Cmp(minInt) < 0 {
+		return nil, fmt.Errorf("share %v is less than min %v", shares[count-1], minInt)
+	}
+	return toStrings(shares), nil
+}
+
+// SplitLucky split total into count random shares with the double mean algorithm:
+// every share get min first, then get a random part of [0, 2 * mean) of the remaining amount,
+// the last share get all the left.
+func SplitLucky(total string, count int, min string) ([]string, error) {
+	return splitDoubleMean(total, count, min, cryptoRandInt)
+}
+
+// NewSplitSeed return the hex seed of a red packet, seed = sha256(createTxHash + ":" + packetId),
+// packetId is the packet id of aptos/eth or the packet object id of sui.
+func NewSplitSeed(createTxHash string, packetId string) string {
+	hash := sha256.Sum256([]byte(createTxHash + ":" + packetId))
+	return hex.EncodeToString(hash[:])
+}
+
+// SplitSeeded same as SplitLucky with min 1, but the random numbers are derived from the hex seed by the seededRand PRNG,
+// so anyone has the seed can re-derive the shares by VerifySplit.
+// The min is fixed by the mode instead of a parameter, then the seed, total and count of the packet are all to verify.
+func SplitSeeded(seed string, total string, count int) ([]string, error) {
+	r, err := newSeededRand(seed)
+	if err != nil {
+		return nil, err
+	}
+	return splitDoubleMean(total, count, "", r.Int)
+}
+
+// VerifySplit re-derive the shares of SplitSeeded and check they are the same as amounts.
+func VerifySplit(seed string, total string, count int, amounts []string) error {
+	shares, err := SplitSeeded(seed, total, count)
+	if err != nil {
+		return err
+	}
+	if len(shares) != len(amounts) {
+		return fmt.Errorf("the number of amounts %d is not the same as the count %d", len(amounts), count)
+	}
+	for i, share := range shares {
+		amount, ok := big.NewInt(0).SetString(amounts[i], 10)
+		if !ok || amount.String() != share {
+			return fmt.Errorf("amount %v at index %d does not match the seeded share %v", amounts[i], i, share)
+		}
+	}
+	return nil
+}
+
+// SplitFixed check the given amounts and return them,
+// the number of amounts must be count, every amount is not less than min and the sum is equal to total.
+func SplitFixed(total string, count int, min string, amounts []string) ([]string, error) {
+	totalInt, minInt, err := parseParams(total, count, min)
+	if err != nil {
+		return nil, err
+	}
+	if len(amounts) != count {
+		return nil, fmt.Errorf("the number of amounts %d is not the same as the count %d", len(amounts), count)
+	}
+	shares := make([]*big.Int, count)
+	sum := big.NewInt(0)
+	for i, amount := range amounts {
+		share, ok := big.NewInt(0).SetString(amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid red packet amount %v", amount)
+		}
+		if share.Cmp(minInt) < 0 {
+			return nil, fmt.Errorf("share %v is less than min %v", share, minInt)
+		}
+		shares[i] = share
+		sum.Add(sum, share)
+	}
+	if sum.Cmp(totalInt) != 0 {
+		return nil, fmt.Errorf("the sum of amounts %v is not equal to total %v", sum, totalInt)
+	}
+	return toStrings(shares), nil
+}
+
+// randIntFunc return a random integer in [0, max)
+type randIntFunc func(max *big.Int) (*big.Int, error)
+
+func cryptoRandInt(max *big.Int) (*big.Int, error) {
+	return rand.Int(rand.Reader, max)
+}
+
+// seededRand is the deterministic PRNG used by SplitSeeded.
+// The byte stream is SHA256(seed || counter) for counter = 0, 1, 2 ..., counter is 8 bytes big endian.
+// A random integer in [0, max) takes the next (byte length of max + 8) bytes from the stream,
+// read them as a big endian integer and mod max, the extra 8 bytes make the modulo bias negligible.
+type seededRand struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func newSeededRand(seed string) (*seededRand, error) {
+	seedBytes, err := hex.DecodeString(strings.TrimPrefix(seed, "0x"))
+	if err != nil || len(seedBytes) == 0 {
+		return nil, fmt.Errorf("invalid seed %v", seed)
+	}
+	return &seededRand{seed: seedBytes}, nil
+}
+
+func (r *seededRand) read(n int) []byte {
+	for len(r.buf) < n {
+		block := make([]byte, len(r.seed)+8)
+		copy(block, r.seed)
+		binary.BigEndian.PutUint64(block[len(r.seed):], r.counter)
+		hash := sha256.Sum256(block)
+		r.buf = append(r.buf, hash[:]...)
+		r.counter++
+	}
+	bytes := r.buf[:n]
+	r.buf = r.buf[n:]
+	return bytes
+}
+
+func (r *seededRand) Int(max *big.Int) (*big.Int, error) {
+	if max.Sign() <= 0 {
+		return nil, errors.New("max must be positive")
+	}
+	n := big.NewInt(0).SetBytes(r.read(len(max.Bytes()) + 8))
+	return n.Mod(n, max), nil
+}
+
+func splitDoubleMean(total string, count int, min string, randInt randIntFunc) ([]string, error) {
+	totalInt, minInt, err := parseParams(total, count, min)
+	if err != nil {
+		return nil, err
+	}
+	// the part that can be distributed randomly after every share get min
+	left := big.NewInt(0).Sub(totalInt, big.NewInt(0).Mul(minInt, big.NewInt(int64(count))))
+	shares := make([]*big.Int, count)
+	for i := 0; i < count-1; i++ {
+		remainCount := big.NewInt(int64(count - i))
+		limit := big.NewInt(0).Mul(left, big.NewInt(2))
+		limit.Quo(limit, remainCount)
+		extra := big.NewInt(0)
+		if limit.Sign() > 0 {
+			extra, err = randInt(limit)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if extra.Cmp(left) > 0 {
+			extra.Set(left)
+		}
+		left.Sub(left, extra)
+		shares[i] = extra.Add(extra, minInt)
+	}
+	shares[count-1] = left.Add(left, minInt)
+	return toStrings(shares), nil
+}
+
+func parseParams(total string, count int, min string) (*big.Int, *big.Int, error) {
+	if count <= 0 {
+		return nil, nil, fmt.Errorf("invalid red packet count %d", count)
+	}
+	totalInt, ok := big.NewInt(0).SetString(total, 10)
+	if !ok || totalInt.Sign() <= 0 {
+		return nil, nil, fmt.Errorf("invalid red packet amount %v", total)
+	}
+	minInt := big.NewInt(1)
+	if min != "" {
+		minInt, ok = big.NewInt(0).SetString(min, 10)
+		if !ok || minInt.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("invalid min amount %v", min)
+		}
+	}
+	if big.NewInt(0).Mul(minInt, big.NewInt(int64(count))).Cmp(totalInt) > 0 {
+		return nil, nil, fmt.Errorf("amount %v is not enough for %d shares of min %v", totalInt, count, minInt)
+	}
+	return totalInt, minInt, nil
+}
+
+func toStrings(shares []*big.Int) []string {
+	amounts := make([]string, len(shares))
+	for i, share := range shares {
+		amounts[i] = share.String()
+	}
+	return amounts
+}
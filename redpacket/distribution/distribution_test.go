@@ -0,0 +1,99 @@
+package distribution
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func checkShares(t *testing.T, shares []string, total string, count int, min string) {
+	require.Len(t, shares, count)
+	totalInt, _ := big.NewInt(0).SetString(total, 10)
+	minInt, _ := big.NewInt(0).SetString(min, 10)
+	sum := big.NewInt(0)
+	for _, share := range shares {
+		shareInt, ok := big.NewInt(0).SetString(share, 10)
+		require.True(t, ok)
+		require.True(t, shareInt.Cmp(minInt) >= 0, "share %v less than min %v", share, min)
+		sum.Add(sum, shareInt)
+	}
+	require.Equal(t, 0, sum.Cmp(totalInt), "sum %v not equal to total %v", sum, total)
+}
+
+func TestSplitEqual(t *testing.T) {
+	shares, err := SplitEqual("100", 3, "1")
+	require.Nil(t, err)
+	require.Equal(t, []string{"34", "33", "33"}, shares)
+
+	// uint256 amount
+	total := "115792089237316195423570985008687907853269984665640564039457584007913129639935"
+	shares, err = SplitEqual(total, 7, "")
+	require.Nil(t, err)
+	checkShares(t, shares, total, 7, "1")
+}
+
+func TestSplitLucky(t *testing.T) {
+	tests := []struct {
+		total string
+		count int
+		min   string
+	}{
+		{"100", 100, "1"},
+		{"100000", 10, "1"},
+		{"100000000", 33, "10000"},
+		{"1000000000000000000000", 200, "1000000000000"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			shares, err := SplitLucky(tt.total, tt.count, tt.min)
+			require.Nil(t, err)
+			checkShares(t, shares, tt.total, tt.count, tt.min)
+		}
+	}
+}
+
+func TestSplitFixed(t *testing.T) {
+	shares, err := SplitFixed("100", 3, "10", []string{"50", "30", "20"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"50", "30", "20"}, shares)
+
+	_, err = SplitFixed("100", 3, "10", []string{"50", "30", "21"})
+	require.NotNil(t, err)
+	_, err = SplitFixed("100", 3, "30", []string{"50", "30", "20"})
+	require.NotNil(t, err)
+	_, err = SplitFixed("100", 2, "10", []string{"50", "30", "20"})
+	require.NotNil(t, err)
+}
+
+func TestSplit_InvalidParams(t *testing.T) {
+	_, err := Split(ModeLucky, "10", 11, "1")
+	require.NotNil(t, err)
+	_, err = Split(ModeEqual, "10", 0, "1")
+	require.NotNil(t, err)
+	_, err = Split(ModeEqual, "abc", 1, "1")
+	require.NotNil(t, err)
+	_, err = Split(ModeFixed, "10", 1, "1")
+	require.NotNil(t, err)
+	_, err = Split("unknown", "10", 1, "1")
+	require.NotNil(t, err)
+}
+
+func TestSplitSeeded(t *testing.T) {
+	seed := NewSplitSeed("0x1908acf431fde3cc31926860c342f18421669d087325defa19cfe42537738c21", "12")
+	require.Equal(t, "deaa66c74c3111a4d908dd75feb7523f2bc4a5dd5f6b16acaf7e06afc6851849", seed)
+
+	// the PRNG is part of the verification protocol, the shares of a seed must never change
+	shares, err := SplitSeeded(seed, "100000000", 5)
+	require.Nil(t, err)
+	require.Equal(t, []string{"28879863", "5487993", "30584745", "23569156", "11478243"}, shares)
+	checkShares(t, shares, "100000000", 5, "1")
+
+	require.Nil(t, VerifySplit(seed, "100000000", 5, shares))
+	require.Nil(t, VerifySplit("0x"+seed, "100000000", 5, shares))
+
+	tampered := []string{"28879864", "5487992", "30584745", "23569156", "11478243"}
+	require.NotNil(t, VerifySplit(seed, "100000000", 5, tampered))
+	require.NotNil(t, VerifySplit(NewSplitSeed("other", "12"), "100000000", 5, shares))
+	require.NotNil(t, VerifySplit("not hex", "100000000", 5, shares))
+}
@@ -1,12 +1,11 @@
 package redpacket
 
 import (
-	"errors"
+	"time"
 
 	"github.com/coming-chat/wallet-SDK/core/aptos"
 	"github.com/coming-chat/wallet-SDK/core/base"
 	"github.com/coming-chat/wallet-SDK/core/eth"
-	"github.com/coming-chat/wallet-SDK/core/sui"
 )
 
 const (
@@ -17,29 +16,58 @@ const (
 
 type ContractConfig struct {
 	SuiConfigAddress string
+
+	// EthFeeCacheDuration cache the eth contract service fee for the duration, 0 means no cache
+	EthFeeCacheDuration time.Duration
+	// EthMulticallAddress the Multicall3 compatible contract to send the eth open batch in one transaction,
+	// it must be a private deployment, the public Multicall3 0xcA11bde05977b3631167028862bE2a173976CA11 is rejected,
+	// empty means the opens are sent with the continuous nonces, see EthOpenBatcher
+	EthMulticallAddress string
+	// EthNonceManager sign the eth transactions locally with the nonces tracked by it,
+	// nil means the nonce is picked by wallet-SDK for each transaction
+	EthNonceManager *EthNonceManager
+	// AptosTokenCacheDuration cache the aptos supported tokens for the duration,
+	// 0 means the default 1 minute, negative means no cache
+	AptosTokenCacheDuration time.Duration
 }
 
 func NewRedPacketContract(chainType string, chain base.Chain, contractAddress string, config *ContractConfig) (RedPacketContract, error) {
 	switch chainType {
 	case ChainTypeEth:
 		if ethChain, ok := chain.(eth.IChain); ok {
-			return NewEthRedPacketContract(ethChain, contractAddress), nil
+			return newEthRedPacketContract(ethChain, contractAddress, config), nil
 		} else {
-			return nil, errors.New("invalid chain object")
+			return nil, newInvalidParamsError("invalid chain object")
 		}
 	case ChainTypeAptos:
 		if aptosChain, ok := chain.(aptos.IChain); ok {
-			return NewAptosRedPacketContract(aptosChain, contractAddress), nil
+			contract := newAptosRedPacketContract(aptosChain, contractAddress, config)
+			if contract == nil {
+				return nil, newInvalidParamsError("invalid contract address")
+			}
+			return contract, nil
 		} else {
-			return nil, errors.New("invalid chain object")
+			return nil, newInvalidParamsError("invalid chain object")
 		}
 	case ChainTypeSui:
-		if suiChain, ok := chain.(*sui.Chain); ok {
+		if suiChain, ok := chain.(SuiChain); ok {
 			return NewSuiRedPacketContract(suiChain, contractAddress, config)
 		} else {
-			return nil, errors.New("invalid chain object")
+			return nil, newInvalidParamsError("invalid chain object")
 		}
 	default:
-		return nil, errors.New("unsupport chain type")
+		return nil, newInvalidParamsError("unsupport chain type")
+	}
+}
+
+// NewRedPacketContractCtx same as NewRedPacketContract, but return the context aware contract
+func NewRedPacketContractCtx(chainType string, chain base.Chain, contractAddress string, config *ContractConfig) (RedPacketContractCtx, error) {
+	contract, err := NewRedPacketContract(chainType, chain, contractAddress, config)
+	if err != nil {
+		return nil, err
+	}
+	if ctxContract, ok := contract.(RedPacketContractCtx); ok {
+		return ctxContract, nil
 	}
+	return nil, newInvalidParamsError("invalid contract object")
 }
@@ -1,5 +1,156 @@
 package redpacket
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+)
+
+// ErrorCode classify the errors returned by all chains, map it to the user-facing message
+type ErrorCode string
+
+const (
+	ErrorCodeUnsupportedToken    ErrorCode = "UnsupportedToken"
+	ErrorCodeInsufficientBalance ErrorCode = "InsufficientBalance"
+	ErrorCodePacketNotFound      ErrorCode = "PacketNotFound"
+	ErrorCodePacketExhausted     ErrorCode = "PacketExhausted" // no remain count or balance to open
+	ErrorCodePacketClosed        ErrorCode = "PacketClosed"
+	ErrorCodeInvalidParams       ErrorCode = "InvalidParams"
+	ErrorCodeFeeMismatch         ErrorCode = "FeeMismatch"
+	ErrorCodeNetwork             ErrorCode = "NetworkError" // retryable
+	ErrorCodeContractAbort       ErrorCode = "ContractAbort"
+)
+
+// the sentinel of the codes, errors.Is(err, ErrPacketClosed) match any error with the same code
+var (
+	ErrUnsupportedToken    = &RedPacketError{Code: ErrorCodeUnsupportedToken}
+	ErrInsufficientBalance = &RedPacketError{Code: ErrorCodeInsufficientBalance}
+	ErrPacketNotFound      = &RedPacketError{Code: ErrorCodePacketNotFound}
+	ErrPacketExhausted     = &RedPacketError{Code: ErrorCodePacketExhausted}
+	ErrPacketClosed        = &RedPacketError{Code: ErrorCodePacketClosed}
+	ErrInvalidParams       = &RedPacketError{Code: ErrorCodeInvalidParams}
+	ErrFeeMismatch         = &RedPacketError{Code: ErrorCodeFeeMismatch}
+	ErrNetwork             = &RedPacketError{Code: ErrorCodeNetwork}
+	ErrContractAbort       = &RedPacketError{Code: ErrorCodeContractAbort}
+)
+
+// ErrInsufficientAllowance the erc20 allowance of the red packet contract is less than the red packet amount,
+// send the action returned by PrepareCreate first. It's also ErrInsufficientBalance.
+var ErrInsufficientAllowance = newRedPacketError(ErrorCodeInsufficientBalance, "insufficient allowance for red packet contract")
+
+// RedPacketError the error with code
+type RedPacketError struct {
+	Code    ErrorCode
+	Message string
+	Err     error // the underlying error
+}
+
+func newRedPacketError(code ErrorCode, message string) error {
+	return &RedPacketError{Code: code, Message: message}
+}
+
+func newInvalidParamsError(format string, a ...interface{}) error {
+	return &RedPacketError{Code: ErrorCodeInvalidParams, Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *RedPacketError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = string(e.Code)
+	}
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *RedPacketError) Unwrap() error {
+	return e.Err
+}
+
+// Is match the sentinel of the same code
+func (e *RedPacketError) Is(target error) bool {
+	return isCodeSentinel(target, e.Code)
+}
+
+func (e *RedPacketError) ErrorCode() ErrorCode {
+	return e.Code
+}
+
+// Retryable the network error can be retried, other errors will fail again
+func (e *RedPacketError) Retryable() bool {
+	return e.Code == ErrorCodeNetwork
+}
+
+func isCodeSentinel(target error, code ErrorCode) bool {
+	t, ok := target.(*RedPacketError)
+	return ok && t.Code == code && t.Message == "" && t.Err == nil
+}
+
+// ErrorCodeOf return the code of the error, empty if the error is not classified
+func ErrorCodeOf(err error) ErrorCode {
+	var codeErr interface{ ErrorCode() ErrorCode }
+	if errors.As(err, &codeErr) {
+		return codeErr.ErrorCode()
+	}
+	return ""
+}
+
+// IsRetryable the error is a network error, the same call may succeed later
+func IsRetryable(err error) bool {
+	return ErrorCodeOf(err) == ErrorCodeNetwork
+}
+
+// networkError classify the rpc transport error as the retryable NetworkError, other errors are returned as is
+func networkError(err error) error {
+	if err == nil || ErrorCodeOf(err) != "" || errors.Is(err, context.Canceled) {
+		return err
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &RedPacketError{Code: ErrorCodeNetwork, Message: "network error", Err: err}
+	}
+	return err
+}
+
+// ContractAbortError the contract aborted the call, the abort of the red packet module is decoded
+// to the named error, see DecodeContractAbort.
+type ContractAbortError struct {
+	AbortCode uint64    // the move abort code, 0 for eth
+	Location  string    // the aborted move module, e.g. 0x1::coin, empty for eth
+	Name      string    // the name of the move abort constant, e.g. EREDPACKET_NOT_FOUND
+	Reason    string    // the eth revert reason or the move abort message
+	Code      ErrorCode // the classified code of the abort, empty if it's unknown
+}
+
+func (e *ContractAbortError) Error() string {
+	if e.Location == "" {
+		return "contract abort: " + e.Reason
+	}
+	if e.Name != "" {
+		return fmt.Sprintf("contract abort in %s with %s(%d): %s", e.Location, e.Name, e.AbortCode, e.Reason)
+	}
+	return fmt.Sprintf("contract abort in %s with code %d: %s", e.Location, e.AbortCode, e.Reason)
+}
+
+// Is match ErrContractAbort and the sentinel of the classified code
+func (e *ContractAbortError) Is(target error) bool {
+	return isCodeSentinel(target, ErrorCodeContractAbort) || (e.Code != "" && isCodeSentinel(target, e.Code))
+}
+
+// ErrorCode return the classified code, ContractAbort if the abort is unknown
+func (e *ContractAbortError) ErrorCode() ErrorCode {
+	if e.Code != "" {
+		return e.Code
+	}
+	return ErrorCodeContractAbort
+}
+
 type RedPacketDataError struct {
 	message string
 }
@@ -11,3 +162,80 @@ func newRedPacketDataError(message string) error {
 func (e *RedPacketDataError) Error() string {
 	return e.message
 }
+
+// InsufficientFeeError the service fee sent with the create transaction is less than the contract required
+type InsufficientFeeError struct {
+	Required string
+	Provided string
+}
+
+func (e *InsufficientFeeError) Error() string {
+	return fmt.Sprintf("insufficient red packet fee, required %s, provided %s", e.Required, e.Provided)
+}
+
+func (e *InsufficientFeeError) Is(target error) bool {
+	return isCodeSentinel(target, ErrorCodeFeeMismatch)
+}
+
+func (e *InsufficientFeeError) ErrorCode() ErrorCode {
+	return ErrorCodeFeeMismatch
+}
+
+// TransactionRevertedError the transaction is executed but failed on chain
+type TransactionRevertedError struct {
+	Hash   string
+	Reason string              // the eth revert message, aptos vm_status or sui execution error
+	Abort  *ContractAbortError // the decoded abort of the reason, nil if it can't be decoded
+}
+
+func (e *TransactionRevertedError) Error() string {
+	if e.Abort != nil && e.Abort.Name != "" {
+		return fmt.Sprintf("transaction %s reverted: %s(%s)", e.Hash, e.Abort.Name, e.Abort.Reason)
+	}
+	return fmt.Sprintf("transaction %s reverted: %s", e.Hash, e.Reason)
+}
+
+func (e *TransactionRevertedError) Unwrap() error {
+	if e.Abort == nil {
+		return nil
+	}
+	return e.Abort
+}
+
+func (e *TransactionRevertedError) Is(target error) bool {
+	return isCodeSentinel(target, ErrorCodeContractAbort)
+}
+
+func (e *TransactionRevertedError) ErrorCode() ErrorCode {
+	if e.Abort != nil {
+		return e.Abort.ErrorCode()
+	}
+	return ErrorCodeContractAbort
+}
+
+// TransactionTimeoutError the transaction is not confirmed before the timeout
+type TransactionTimeoutError struct {
+	Hash string
+	Err  error // the last rpc error while polling, nil if the transaction is just pending
+}
+
+func (e *TransactionTimeoutError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("wait for transaction %s timeout, last error: %v", e.Hash, e.Err)
+	}
+	return fmt.Sprintf("wait for transaction %s timeout", e.Hash)
+}
+
+func (e *TransactionTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TransactionDroppedError the transaction is not found on chain for a long time,
+// it may be dropped from the mempool or expired, it's safe to resend the action.
+type TransactionDroppedError struct {
+	Hash string
+}
+
+func (e *TransactionDroppedError) Error() string {
+	return fmt.Sprintf("transaction %s is dropped", e.Hash)
+}
@@ -0,0 +1,44 @@
+package redpacket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedPacketError_Is(t *testing.T) {
+	err := fmt.Errorf("open: %w", newRedPacketError(ErrorCodePacketClosed, "red packet 1 is closed"))
+	require.ErrorIs(t, err, ErrPacketClosed)
+	require.NotErrorIs(t, err, ErrPacketExhausted)
+	require.Equal(t, ErrorCodePacketClosed, ErrorCodeOf(err))
+	require.False(t, IsRetryable(err))
+	require.EqualError(t, err, "open: red packet 1 is closed")
+
+	// the sentinel with message only match itself
+	require.ErrorIs(t, ErrInsufficientAllowance, ErrInsufficientBalance)
+	require.NotErrorIs(t, newRedPacketError(ErrorCodeInsufficientBalance, "insufficient balance"), ErrInsufficientAllowance)
+
+	require.ErrorIs(t, &InsufficientFeeError{Required: "2", Provided: "1"}, ErrFeeMismatch)
+	require.ErrorIs(t, &TransactionRevertedError{Hash: "0x1", Reason: "abort"}, ErrContractAbort)
+	require.ErrorIs(t, &ContractAbortError{Reason: "abort"}, ErrContractAbort)
+	require.Equal(t, ErrorCode(""), ErrorCodeOf(newRedPacketDataError("invalid data")))
+}
+
+func Test_networkError(t *testing.T) {
+	transportErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: errors.New("connection refused")}
+	err := networkError(transportErr)
+	require.True(t, IsRetryable(err))
+	require.ErrorIs(t, err, ErrNetwork)
+	require.True(t, errors.Is(err, transportErr))
+
+	cancelErr := &url.Error{Op: "Post", URL: "http://127.0.0.1:1", Err: context.Canceled}
+	require.False(t, IsRetryable(networkError(cancelErr)))
+
+	rpcErr := errors.New("execution reverted")
+	require.Equal(t, rpcErr, networkError(rpcErr))
+	require.Nil(t, networkError(nil))
+}
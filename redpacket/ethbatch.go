@@ -0,0 +1,339 @@
+package redpacket
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Multicall3ABI the aggregate3 function of the Multicall3 compatible contract
+const Multicall3ABI = `[{"inputs":[{"components":[{"internalType":"address","name":"target","type":"address"},{"internalType":"bool","name":"allowFailure","type":"bool"},{"internalType":"bytes","name":"callData","type":"bytes"}],"internalType":"struct Multicall3.Call3[]","name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"internalType":"bool","name":"success","type":"bool"},{"internalType":"bytes","name":"returnData","type":"bytes"}],"internalType":"struct Multicall3.Result[]","name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+
+var multicall3ABI, _ = abi.JSON(strings.NewReader(Multicall3ABI))
+
+// ethPublicMulticall3Address the canonical Multicall3 deployment that forwards anyone's calls,
+// everyone could open the red packets through it if it's the admin.
+var ethPublicMulticall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+// multicall3Call the Multicall3.Call3 tuple, the field names are matched with the abi components
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// multicall3Result the Multicall3.Result tuple
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// EthOpenBatcher send the opens of different red packets in batch,
+// the contract returned by NewEthRedPacketContract can be asserted to it.
+//
+// With ContractConfig.EthMulticallAddress the opens are sent in one aggregate3 transaction of the multicall contract,
+// the red packet open checks the msg.sender, so the admin of the red packet contract must be the multicall contract,
+// which should be a private deployment that only forwards the calls of the operator.
+// Without it, each open is sent in it's own transaction with the continuous nonces without waiting the previous one,
+// the nonces are taken from ContractConfig.EthNonceManager if it's configured.
+type EthOpenBatcher interface {
+	SendOpenBatch(account base.Account, opens []*RedPacketAction) ([]*EthOpenResult, error)
+	FetchOpenBatchResult(results []*EthOpenResult) ([]*EthOpenResult, error)
+
+	SendOpenBatchWithContext(ctx context.Context, account base.Account, opens []*RedPacketAction) ([]*EthOpenResult, error)
+	FetchOpenBatchResultWithContext(ctx context.Context, results []*EthOpenResult) ([]*EthOpenResult, error)
+}
+
+// EthOpenResult 批量打开中一个红包的结果
+type EthOpenResult struct {
+	PacketId       int64
+	Hash           string                 // 所在的交易, multicall 模式下所有红包共用一个交易, 未发送时为空
+	Status         base.TransactionStatus // 交易成功也可能只有部分红包打开成功, 以这里的状态为准
+	FailureMessage string
+	Abort          *ContractAbortError // 解码出的合约错误
+	Event          *RedPacketEvent     // 打开成功后的 UpdateRedEnvelop 事件
+}
+
+func (contract *ethRedPacketContract) SendOpenBatch(account base.Account, opens []*RedPacketAction) ([]*EthOpenResult, error) {
+	return contract.SendOpenBatchWithContext(context.Background(), account, opens)
+}
+
+// SendOpenBatchWithContext the opens predicted to revert are marked failed and not sent.
+// The error is returned only when nothing is sent, once any open is sent the results are returned,
+// the opens failed to send after it are marked failed with an empty hash.
+func (contract *ethRedPacketContract) SendOpenBatchWithContext(ctx context.Context, account base.Account, opens []*RedPacketAction) ([]*EthOpenResult, error) {
+	if len(opens) == 0 {
+		return nil, newInvalidParamsError("empty open batch")
+	}
+	results := make([]*EthOpenResult, len(opens))
+	datas := make([][]byte, len(opens))
+	packetIds := make(map[int64]bool, len(opens))
+	for i, rpa := range opens {
+		if rpa == nil || rpa.Method != RPAMethodOpen || rpa.OpenParams == nil {
+			return nil, newInvalidParamsError("only open is supported in the open batch")
+		}
+		packetId := rpa.OpenParams.PacketId
+		if packetIds[packetId] {
+			// the later open will revert or overwrite the result of the earlier one
+			return nil, newInvalidParamsError("duplicate red packet %v in the open batch", packetId)
+		}
+		packetIds[packetId] = true
+
+		_, data, _, err := contract.transactionData(ctx, rpa)
+		if err != nil {
+			return nil, err
+		}
+		datas[i] = data
+		results[i] = &EthOpenResult{PacketId: packetId, Status: base.TransactionStatusPending}
+	}
+
+	if contract.multicallAddress != "" {
+		return contract.sendMulticallOpens(ctx, account, results, datas)
+	}
+	return contract.sendPipelineOpens(ctx, account, results, datas)
+}
+
+// sendMulticallOpens eth_call the aggregate3 to find the opens that will revert, and send the others in one transaction
+func (contract *ethRedPacketContract) sendMulticallOpens(ctx context.Context, account base.Account, results []*EthOpenResult, datas [][]byte) ([]*EthOpenResult, error) {
+	if !common.IsHexAddress(contract.multicallAddress) {
+		return nil, newInvalidParamsError("invalid multicall address %v", contract.multicallAddress)
+	}
+	if common.HexToAddress(contract.multicallAddress) == ethPublicMulticall3Address {
+		return nil, newInvalidParamsError("the public Multicall3 %v can't be the red packet admin, deploy a private forwarder", contract.multicallAddress)
+	}
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+
+	target := common.HexToAddress(contract.address)
+	calls := make([]multicall3Call, len(datas))
+	for i, data := range datas {
+		calls[i] = multicall3Call{Target: target, AllowFailure: true, CallData: data}
+	}
+	simulated, err := contract.callMulticall(ctx, client, account.Address(), calls)
+	if err != nil {
+		return nil, err
+	}
+
+	var sendCalls []multicall3Call
+	var sendResults []*EthOpenResult
+	for i, result := range simulated {
+		if !result.Success {
+			reason, _ := decodeEthRevertData(result.ReturnData)
+			markOpenFailed(results[i], newEthRevertError(reason))
+			continue
+		}
+		sendCalls = append(sendCalls, calls[i])
+		sendResults = append(sendResults, results[i])
+	}
+	if len(sendCalls) == 0 {
+		return results, nil
+	}
+
+	data, err := multicall3ABI.Pack("aggregate3", sendCalls)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := contract.sendData(ctx, account, contract.multicallAddress, data, big.NewInt(0))
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range sendResults {
+		result.Hash = hash
+	}
+	return results, nil
+}
+
+// callMulticall eth_call the aggregate3 from the sender, the results are in the same order of the calls
+func (contract *ethRedPacketContract) callMulticall(ctx context.Context, client *ethclient.Client, from string, calls []multicall3Call) ([]multicall3Result, error) {
+	data, err := multicall3ABI.Pack("aggregate3", calls)
+	if err != nil {
+		return nil, err
+	}
+	to := common.HexToAddress(contract.multicallAddress)
+	output, err := client.CallContract(ctx, ethereum.CallMsg{From: common.HexToAddress(from), To: &to, Data: data}, nil)
+	if err != nil {
+		if abort := ethRevertError(err); abort != nil {
+			return nil, abort
+		}
+		return nil, networkError(err)
+	}
+	results, err := unpackMulticallResults(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(calls) {
+		return nil, newRedPacketDataError("the multicall results is not the same as the calls")
+	}
+	return results, nil
+}
+
+func unpackMulticallResults(output []byte) ([]multicall3Result, error) {
+	values, err := multicall3ABI.Unpack("aggregate3", output)
+	if err != nil {
+		return nil, newRedPacketDataError(err.Error())
+	}
+	if len(values) == 0 {
+		return nil, newRedPacketDataError("empty multicall results")
+	}
+	results, ok := abi.ConvertType(values[0], new([]multicall3Result)).(*[]multicall3Result)
+	if !ok {
+		return nil, newRedPacketDataError("invalid multicall results")
+	}
+	return *results, nil
+}
+
+// sendPipelineOpens sign and send the opens with the continuous nonces of the nonce manager,
+// the open that will revert is skipped and doesn't take the nonce, so the later ones are not stuck.
+func (contract *ethRedPacketContract) sendPipelineOpens(ctx context.Context, account base.Account, results []*EthOpenResult, datas [][]byte) ([]*EthOpenResult, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := newEthLegacySigner(ctx, client, account)
+	if err != nil {
+		return nil, err
+	}
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, networkError(err)
+	}
+
+	nonces := contract.nonces()
+	to := common.HexToAddress(contract.address)
+	sent := 0
+	for i, data := range datas {
+		gasLimit, err := contract.estimateGasLimit(ctx, client, signer.from.String(), contract.address, data, big.NewInt(0), price)
+		if err == nil {
+			var tx *types.Transaction
+			tx, err = nonces.send(ctx, client, signer.from, func(nonce uint64) (*types.Transaction, error) {
+				return signer.sign(nonce, price, gasLimit, to, big.NewInt(0), data)
+			})
+			if err == nil {
+				results[i].Hash = tx.Hash().String()
+				sent++
+				continue
+			}
+		}
+
+		var abort *ContractAbortError
+		if errors.As(err, &abort) {
+			markOpenFailed(results[i], abort)
+			continue
+		}
+		if sent == 0 {
+			return nil, err
+		}
+		// the results of the sent opens are kept, the nonce manager resyncs the nonce at the next send
+		for _, result := range results[i:] {
+			if result.Status == base.TransactionStatusPending {
+				result.Status = base.TransactionStatusFailure
+				result.FailureMessage = "not sent: " + err.Error()
+			}
+		}
+		break
+	}
+	return results, nil
+}
+
+func markOpenFailed(result *EthOpenResult, abort *ContractAbortError) {
+	result.Status = base.TransactionStatusFailure
+	result.FailureMessage = abort.Reason
+	result.Abort = abort
+}
+
+func (contract *ethRedPacketContract) FetchOpenBatchResult(results []*EthOpenResult) ([]*EthOpenResult, error) {
+	return contract.FetchOpenBatchResultWithContext(context.Background(), results)
+}
+
+// FetchOpenBatchResultWithContext update the pending results with their transactions, the results passed in are not modified.
+// The open is succeeded only if the transaction has it's UpdateRedEnvelop event,
+// the transaction not found is kept pending, it may be still in the mempool or dropped.
+func (contract *ethRedPacketContract) FetchOpenBatchResultWithContext(ctx context.Context, results []*EthOpenResult) ([]*EthOpenResult, error) {
+	updated := make([]*EthOpenResult, len(results))
+	pendings := make(map[string][]*EthOpenResult)
+	var hashes []string
+	for i, result := range results {
+		copied := *result
+		updated[i] = &copied
+		if copied.Status != base.TransactionStatusPending || copied.Hash == "" {
+			continue
+		}
+		if _, ok := pendings[copied.Hash]; !ok {
+			hashes = append(hashes, copied.Hash)
+		}
+		pendings[copied.Hash] = append(pendings[copied.Hash], &copied)
+	}
+
+	for _, hash := range hashes {
+		detail, err := contract.transactionStatus(ctx, hash)
+		if errors.Is(err, errTransactionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch detail.Status {
+		case base.TransactionStatusFailure:
+			abort := transactionAbort(detail)
+			if abort == nil {
+				abort = newEthRevertError(detail.FailureMessage)
+			}
+			for _, result := range pendings[hash] {
+				markOpenFailed(result, abort)
+				result.FailureMessage = detail.FailureMessage
+			}
+		case base.TransactionStatusSuccess:
+			events, err := contract.openEvents(ctx, hash)
+			if err != nil {
+				return nil, err
+			}
+			for _, result := range pendings[hash] {
+				if event, ok := events[result.PacketId]; ok {
+					result.Status = base.TransactionStatusSuccess
+					result.Event = event
+				} else {
+					// the call failed inside the multicall, the transaction itself is succeeded
+					markOpenFailed(result, newEthRevertError("open reverted in the multicall"))
+				}
+			}
+		}
+	}
+	return updated, nil
+}
+
+// openEvents the UpdateRedEnvelop events of the transaction by the red packet id
+func (contract *ethRedPacketContract) openEvents(ctx context.Context, hash string) (map[int64]*RedPacketEvent, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(hash))
+	if err != nil {
+		return nil, networkError(err)
+	}
+	return contract.openEventsFromLogs(receipt.Logs)
+}
+
+func (contract *ethRedPacketContract) openEventsFromLogs(logs []*types.Log) (map[int64]*RedPacketEvent, error) {
+	updates, err := contract.updateRedEnvelopEvents(logs)
+	if err != nil {
+		return nil, err
+	}
+	events := make(map[int64]*RedPacketEvent, len(updates))
+	for _, event := range updates {
+		events[event.PacketId] = event
+	}
+	return events, nil
+}
+
+var _ EthOpenBatcher = (*ethRedPacketContract)(nil)
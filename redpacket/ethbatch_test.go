@@ -0,0 +1,102 @@
+package redpacket
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/coming-chat/go-red-packet/redpacket/chainstub"
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/coming-chat/wallet-SDK/core/eth"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_unpackMulticallResults(t *testing.T) {
+	revertData := []byte{0x4e, 0x48, 0x7b, 0x71}
+	output, err := multicall3ABI.Methods["aggregate3"].Outputs.Pack([]multicall3Result{
+		{Success: true, ReturnData: []byte{}},
+		{Success: false, ReturnData: revertData},
+	})
+	require.Nil(t, err)
+
+	results, err := unpackMulticallResults(output)
+	require.Nil(t, err)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Success)
+	require.False(t, results[1].Success)
+	require.Equal(t, revertData, results[1].ReturnData)
+
+	_, err = unpackMulticallResults([]byte{0x01})
+	require.NotNil(t, err)
+}
+
+func TestEth_SendOpenBatchInvalidParams(t *testing.T) {
+	contract := newEthRedPacketContract(eth.NewChainWithRpc("http://127.0.0.1:0"), fixtureEthContract, nil)
+	open1, err := NewRedPacketActionOpen("", 1, []string{fixtureEthCreator}, []string{"1"})
+	require.Nil(t, err)
+	open2, err := NewRedPacketActionOpen("", 1, []string{fixtureEthCreator}, []string{"2"})
+	require.Nil(t, err)
+	closeAction, err := NewRedPacketActionClose("", 2, fixtureEthCreator, "")
+	require.Nil(t, err)
+
+	_, err = contract.SendOpenBatch(nil, nil)
+	require.ErrorIs(t, err, ErrInvalidParams)
+	_, err = contract.SendOpenBatch(nil, []*RedPacketAction{open1, open2})
+	require.ErrorIs(t, err, ErrInvalidParams)
+	_, err = contract.SendOpenBatch(nil, []*RedPacketAction{open1, closeAction})
+	require.ErrorIs(t, err, ErrInvalidParams)
+
+	// anyone can call through the public Multicall3
+	contract = newEthRedPacketContract(eth.NewChainWithRpc("http://127.0.0.1:0"), fixtureEthContract, &ContractConfig{
+		EthMulticallAddress: "0xca11bde05977b3631167028862be2a173976ca11",
+	})
+	_, err = contract.SendOpenBatch(nil, []*RedPacketAction{open1})
+	require.ErrorIs(t, err, ErrInvalidParams)
+}
+
+func updateRedEnvelopLog(packetId, remainCount, remainBalance int64) map[string]interface{} {
+	return map[string]interface{}{
+		"address":          fixtureEthContract,
+		"topics":           []string{redPacketABI.Events["UpdateRedEnvelop"].ID.Hex()},
+		"data":             fmt.Sprintf("0x%064x%064x%064x", packetId, remainCount, remainBalance),
+		"blockNumber":      "0xf42400",
+		"transactionHash":  fixtureEthCreateTx,
+		"transactionIndex": "0x0",
+		"blockHash":        "0x854a426738f72584e97af0d17e0f6b85aa663559ac7b6f674d1eae7fae134dba",
+		"logIndex":         "0x0",
+		"removed":          false,
+	}
+}
+
+func TestEth_FetchOpenBatchResultWithFixture(t *testing.T) {
+	var receipt map[string]interface{}
+	loadFixture(t, "eth/create_receipt.json", &receipt)
+	// only the open of packet 1 landed in the multicall transaction
+	receipt["logs"] = []interface{}{updateRedEnvelopLog(1, 2, 3000)}
+
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+	server.HandleResult("eth_getTransactionReceipt", receipt)
+	require.Nil(t, server.HandleFixture("eth_getTransactionByHash", fixturePath("eth/create_transaction.json")))
+	require.Nil(t, server.HandleFixture("eth_getBlockByHash", fixturePath("eth/block_header.json")))
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	sent := []*EthOpenResult{
+		{PacketId: 1, Hash: fixtureEthCreateTx, Status: base.TransactionStatusPending},
+		{PacketId: 2, Hash: fixtureEthCreateTx, Status: base.TransactionStatusPending},
+		{PacketId: 3, Status: base.TransactionStatusFailure, FailureMessage: "not sent: EOF"},
+	}
+	results, err := contract.FetchOpenBatchResult(sent)
+	require.Nil(t, err)
+	require.Len(t, results, 3)
+
+	require.Equal(t, base.TransactionStatusSuccess, results[0].Status)
+	require.Equal(t, &RedPacketEvent{Method: RPAMethodOpen, PacketId: 1, RemainCount: 2, RemainBalance: "3000"}, results[0].Event)
+	require.Equal(t, base.TransactionStatusFailure, results[1].Status)
+	require.NotNil(t, results[1].Abort)
+	require.Nil(t, results[1].Event)
+	require.Equal(t, sent[2], results[2])
+
+	// the results passed in are not modified
+	require.Equal(t, base.TransactionStatusPending, sent[0].Status)
+}
@@ -1,101 +1,498 @@
 package redpacket
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/coming-chat/wallet-SDK/core/base"
 	"github.com/coming-chat/wallet-SDK/core/eth"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 const RedPacketABI = `[{"inputs":[{"internalType":"address","name":"_admin","type":"address"},{"internalType":"address","name":"_beneficiary","type":"address"},{"internalType":"uint256","name":"_base_fee","type":"uint256"}],"stateMutability":"nonpayable","type":"constructor"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"_old","type":"address"},{"indexed":false,"internalType":"address","name":"_new","type":"address"}],"name":"AdminChanged","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"_old","type":"address"},{"indexed":false,"internalType":"address","name":"_new","type":"address"}],"name":"BeneficiaryChanged","type":"event"},{"inputs":[{"internalType":"uint256","name":"id","type":"uint256"},{"internalType":"address","name":"maybe_creator","type":"address"}],"name":"close","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"contract IERC20","name":"token","type":"address"},{"internalType":"uint256","name":"count","type":"uint256"},{"internalType":"uint256","name":"total_balance","type":"uint256"}],"name":"create","outputs":[],"stateMutability":"payable","type":"function"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"_fee","type":"uint256"}],"name":"NewBasePrepaidFee","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"_id","type":"uint256"},{"indexed":false,"internalType":"contract IERC20","name":"_token","type":"address"},{"indexed":false,"internalType":"uint256","name":"_count","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"_balance","type":"uint256"}],"name":"NewRedEnvelop","type":"event"},{"inputs":[{"internalType":"uint256","name":"id","type":"uint256"},{"internalType":"address[]","name":"luck_accounts","type":"address[]"},{"internalType":"uint256[]","name":"balances","type":"uint256[]"}],"name":"open","outputs":[],"stateMutability":"nonpayable","type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"previousOwner","type":"address"},{"indexed":true,"internalType":"address","name":"newOwner","type":"address"}],"name":"OwnershipTransferred","type":"event"},{"inputs":[],"name":"renounceOwnership","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"new_admin","type":"address"}],"name":"set_admin","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"new_beneficiary","type":"address"}],"name":"set_beneficiary","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"uint256","name":"new_fee","type":"uint256"}],"name":"set_prepaid_fee","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"newOwner","type":"address"}],"name":"transferOwnership","outputs":[],"stateMutability":"nonpayable","type":"function"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"_id","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"_remain_count","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"_remain_balance","type":"uint256"}],"name":"UpdateRedEnvelop","type":"event"},{"inputs":[],"name":"admin","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"base_prepaid_fee","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"beneficiary","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"count","type":"uint256"}],"name":"calc_prepaid_fee","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"id","type":"uint256"}],"name":"is_valid","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"max_count","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"next_id","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"owner","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"","type":"uint256"}],"name":"red_envelop_infos","outputs":[{"internalType":"contract IERC20","name":"token","type":"address"},{"internalType":"uint256","name":"remain_count","type":"uint256"},{"internalType":"uint256","name":"remain_balance","type":"uint256"}],"stateMutability":"view","type":"function"}]`
 
-// ethRedPacketContract implement RedPacketContract interface
+const erc20ABIString = `[{"inputs":[],"name":"name","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"decimals","outputs":[{"internalType":"uint8","name":"","type":"uint8"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"owner","type":"address"},{"internalType":"address","name":"spender","type":"address"}],"name":"allowance","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"spender","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"approve","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"account","type":"address"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"from","type":"address"},{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+
+var (
+	redPacketABI, _ = abi.JSON(strings.NewReader(RedPacketABI))
+	erc20ABI, _     = abi.JSON(strings.NewReader(erc20ABIString))
+)
+
+// ethRedPacketContract implement RedPacketContract and RedPacketContractCtx interface
 type ethRedPacketContract struct {
 	chain   eth.IChain
 	address string
+
+	feeCacheDuration time.Duration
+	feeCacheLock     sync.Mutex
+	feeCache         map[int]ethFeeCacheItem   // key is the red packet count
+	feeTransactions  map[common.Hash]time.Time // the pending set_prepaid_fee transactions and when to stop watching, the fee is not cached until they are confirmed
+
+	multicallAddress string
+	nonceManager     *EthNonceManager
+}
+
+type ethFeeCacheItem struct {
+	fee       *big.Int
+	expiredAt time.Time
+}
+
+// EthRedPacketAdmin read the admin configs of the eth red packet contract,
+// the contract returned by NewEthRedPacketContract can be asserted to it.
+type EthRedPacketAdmin interface {
+	Admin() (string, error)
+	Beneficiary() (string, error)
+	Owner() (string, error)
+	MaxCount() (int64, error)
+	NextId() (int64, error)
+
+	AdminWithContext(ctx context.Context) (string, error)
+	BeneficiaryWithContext(ctx context.Context) (string, error)
+	OwnerWithContext(ctx context.Context) (string, error)
+	MaxCountWithContext(ctx context.Context) (int64, error)
+	NextIdWithContext(ctx context.Context) (int64, error)
 }
 
 func NewEthRedPacketContract(chain eth.IChain, contractAddress string) RedPacketContract {
-	return &ethRedPacketContract{chain: chain, address: contractAddress}
+	return newEthRedPacketContract(chain, contractAddress, nil)
+}
+
+func newEthRedPacketContract(chain eth.IChain, contractAddress string, config *ContractConfig) *ethRedPacketContract {
+	contract := &ethRedPacketContract{
+		chain:           chain,
+		address:         contractAddress,
+		feeCache:        make(map[int]ethFeeCacheItem),
+		feeTransactions: make(map[common.Hash]time.Time),
+	}
+	if config != nil {
+		contract.feeCacheDuration = config.EthFeeCacheDuration
+		contract.multicallAddress = config.EthMulticallAddress
+		contract.nonceManager = config.EthNonceManager
+	}
+	return contract
 }
 
 func (contract *ethRedPacketContract) EstimateFee(rpa *RedPacketAction) (string, error) {
+	return contract.EstimateFeeWithContext(context.Background(), rpa)
+}
+
+// EstimateFeeWithContext the create fee is read from the contract calc_prepaid_fee,
+// fallback to base_prepaid_fee * rate, and then the default base fee when the contract doesn't have the method.
+func (contract *ethRedPacketContract) EstimateFeeWithContext(ctx context.Context, rpa *RedPacketAction) (string, error) {
 	switch rpa.Method {
 	case RPAMethodCreate:
-		count := rpa.CreateParams.Count
-		rate := 200.0
-		switch {
-		case count <= 10:
-			rate = 4
-		case count <= 100:
-			rate = 16
-		case count <= 1000:
-			rate = 200
-		}
-		feeFloat := big.NewFloat(0.025 * rate)
-		feeFloat.Mul(feeFloat, big.NewFloat(1e18))
-		feeInt, _ := feeFloat.Int(big.NewInt(0))
-		return feeInt.String(), nil
+		if rpa.CreateParams == nil {
+			return "", newInvalidParamsError("invalid create params")
+		}
+		fee, _, err := contract.createFee(ctx, rpa.CreateParams.Count)
+		if err != nil {
+			return "", err
+		}
+		return fee.String(), nil
 	default:
 		return "0", nil
 	}
 }
 
-func (contract *ethRedPacketContract) EstimateGasFee(account base.Account, rpa *RedPacketAction) (string, error) {
-	params, err := contract.packParams(rpa)
+// createFee return the create fee of the count and whether it's from the cache
+func (contract *ethRedPacketContract) createFee(ctx context.Context, count int) (*big.Int, bool, error) {
+	if fee, ok := contract.cachedFee(count); ok {
+		return fee, true, nil
+	}
+	fee, err := contract.prepaidFee(ctx, count)
+	if err == nil {
+		contract.setCachedFee(count, fee)
+		return fee, false, nil
+	}
+	if !isEthViewUnavailable(err) {
+		return nil, false, ethViewError(err)
+	}
+	basePrepaid, err := contract.basePrepaidFee(ctx)
 	if err != nil {
-		return "", err
+		if !isEthViewUnavailable(err) {
+			return nil, false, ethViewError(err)
+		}
+		basePrepaid = big.NewInt(ethDefaultBasePrepaidFee)
 	}
-	data, err := eth.EncodeContractData(RedPacketABI, rpa.Method, params...)
+	// the fallback fee is not cached, try the contract again next time
+	return basePrepaid.Mul(basePrepaid, big.NewInt(ethPrepaidFeeRate(count))), false, nil
+}
+
+// isEthViewUnavailable the view call returned nothing, or reverted without any reason or error data,
+// which is how the contract without the method fails. The revert with a reason is a real failure of the method.
+func isEthViewUnavailable(err error) bool {
+	if strings.Contains(err.Error(), "empty string while arguments are expected") {
+		return true
+	}
+	abort := ethRevertError(err)
+	return abort != nil && abort.Reason == "execution reverted"
+}
+
+// ethViewError return the revert of the view call as *ContractAbortError, other errors as is
+func ethViewError(err error) error {
+	if abort := ethRevertError(err); abort != nil {
+		return abort
+	}
+	return err
+}
+
+// ethDefaultBasePrepaidFee 0.025 eth, the base_prepaid_fee when the contract was deployed
+const ethDefaultBasePrepaidFee = 25e15
+
+// ethPrepaidFeeRate the rate of base_prepaid_fee by the red packet count, same as the contract calc_prepaid_fee
+func ethPrepaidFeeRate(count int) int64 {
+	switch {
+	case count <= 10:
+		return 4
+	case count <= 100:
+		return 16
+	default:
+		return 200
+	}
+}
+
+func (contract *ethRedPacketContract) Admin() (string, error) {
+	return contract.AdminWithContext(context.Background())
+}
+
+func (contract *ethRedPacketContract) AdminWithContext(ctx context.Context) (string, error) {
+	return contract.callAddress(ctx, "admin")
+}
+
+func (contract *ethRedPacketContract) Beneficiary() (string, error) {
+	return contract.BeneficiaryWithContext(context.Background())
+}
+
+func (contract *ethRedPacketContract) BeneficiaryWithContext(ctx context.Context) (string, error) {
+	return contract.callAddress(ctx, "beneficiary")
+}
+
+func (contract *ethRedPacketContract) Owner() (string, error) {
+	return contract.OwnerWithContext(context.Background())
+}
+
+func (contract *ethRedPacketContract) OwnerWithContext(ctx context.Context) (string, error) {
+	return contract.callAddress(ctx, "owner")
+}
+
+func (contract *ethRedPacketContract) MaxCount() (int64, error) {
+	return contract.MaxCountWithContext(context.Background())
+}
+
+func (contract *ethRedPacketContract) MaxCountWithContext(ctx context.Context) (int64, error) {
+	return contract.callUint(ctx, "max_count")
+}
+
+// NextId the id of the next created red packet
+func (contract *ethRedPacketContract) NextId() (int64, error) {
+	return contract.NextIdWithContext(context.Background())
+}
+
+func (contract *ethRedPacketContract) NextIdWithContext(ctx context.Context) (int64, error) {
+	return contract.callUint(ctx, "next_id")
+}
+
+// callAddress call the view function without params that return an address
+func (contract *ethRedPacketContract) callAddress(ctx context.Context, method string) (string, error) {
+	res, err := contract.callContract(ctx, method)
 	if err != nil {
 		return "", err
 	}
+	address, ok := res[0].(common.Address)
+	if !ok {
+		return "", newRedPacketDataError(fmt.Sprintf("%s is not address", method))
+	}
+	return address.String(), nil
+}
 
-	ethChain, err := contract.chain.GetEthChain()
+// callUint call the view function without params that return an uint256
+func (contract *ethRedPacketContract) callUint(ctx context.Context, method string) (int64, error) {
+	res, err := contract.callContract(ctx, method)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
+	value, ok := res[0].(*big.Int)
+	if !ok || !value.IsInt64() {
+		return 0, newRedPacketDataError(fmt.Sprintf("%s is not int64", method))
+	}
+	return value.Int64(), nil
+}
+
+// prepaidFee call the contract calc_prepaid_fee(count)
+func (contract *ethRedPacketContract) prepaidFee(ctx context.Context, count int) (*big.Int, error) {
+	res, err := contract.callContract(ctx, "calc_prepaid_fee", big.NewInt(int64(count)))
+	if err != nil {
+		return nil, err
+	}
+	fee, ok := res[0].(*big.Int)
+	if !ok {
+		return nil, newRedPacketDataError("calc_prepaid_fee is not uint256")
+	}
+	return fee, nil
+}
 
-	price, err := ethChain.SuggestGasPrice()
+// basePrepaidFee call the contract base_prepaid_fee()
+func (contract *ethRedPacketContract) basePrepaidFee(ctx context.Context) (*big.Int, error) {
+	res, err := contract.callContract(ctx, "base_prepaid_fee")
+	if err != nil {
+		return nil, err
+	}
+	fee, ok := res[0].(*big.Int)
+	if !ok {
+		return nil, newRedPacketDataError("base_prepaid_fee is not uint256")
+	}
+	return fee, nil
+}
+
+func (contract *ethRedPacketContract) cachedFee(count int) (*big.Int, bool) {
+	if contract.feeCacheDuration <= 0 {
+		return nil, false
+	}
+	contract.feeCacheLock.Lock()
+	defer contract.feeCacheLock.Unlock()
+	if contract.hasPendingFeeTransaction() {
+		return nil, false
+	}
+	item, ok := contract.feeCache[count]
+	if !ok || time.Now().After(item.expiredAt) {
+		return nil, false
+	}
+	return big.NewInt(0).Set(item.fee), true
+}
+
+func (contract *ethRedPacketContract) setCachedFee(count int, fee *big.Int) {
+	if contract.feeCacheDuration <= 0 {
+		return
+	}
+	contract.feeCacheLock.Lock()
+	defer contract.feeCacheLock.Unlock()
+	if contract.hasPendingFeeTransaction() {
+		return
+	}
+	contract.feeCache[count] = ethFeeCacheItem{
+		fee:       big.NewInt(0).Set(fee),
+		expiredAt: time.Now().Add(contract.feeCacheDuration),
+	}
+}
+
+// checkPrepaidFee check the cached fee is enough for the current contract fee, the create transaction
+// will revert if the admin has raised the fee, the check is skipped when the contract doesn't have the method.
+func (contract *ethRedPacketContract) checkPrepaidFee(ctx context.Context, count int, fee *big.Int) error {
+	required, err := contract.prepaidFee(ctx, count)
+	if err != nil {
+		if isEthViewUnavailable(err) {
+			return nil
+		}
+		return ethViewError(err)
+	}
+	if fee.Cmp(required) < 0 {
+		contract.feeCacheLock.Lock()
+		delete(contract.feeCache, count)
+		contract.feeCacheLock.Unlock()
+		return &InsufficientFeeError{Required: required.String(), Provided: fee.String()}
+	}
+	return nil
+}
+
+func (contract *ethRedPacketContract) EstimateGasFee(account base.Account, rpa *RedPacketAction) (string, error) {
+	return contract.EstimateGasFeeWithContext(context.Background(), account, rpa)
+}
+
+func (contract *ethRedPacketContract) EstimateGasFeeWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (string, error) {
+	toAddress, data, value, err := contract.transactionData(ctx, rpa)
 	if err != nil {
 		return "", err
 	}
 
-	value, err := contract.EstimateFee(rpa)
+	client, err := contract.remoteClient()
 	if err != nil {
 		return "", err
 	}
 
-	msg := eth.NewCallMsg()
-	msg.SetFrom(account.Address())
-	msg.SetTo(contract.address)
-	msg.SetGasPrice(price)
-	msg.SetData(data)
-	msg.SetValue(value)
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return "", networkError(err)
+	}
+
+	valueInt, ok := big.NewInt(0).SetString(value, 10)
+	if !ok {
+		return "", newInvalidParamsError("invalid fee value")
+	}
 
-	gasLimit, err := contract.chain.EstimateGasLimit(msg)
+	gasLimit, err := contract.estimateGasLimit(ctx, client, account.Address(), toAddress, data, valueInt, price)
 	if err != nil {
-		gasLimit = &base.OptionalString{Value: "200000"}
-		err = nil
+		return "", err
 	}
+	return price.Mul(price, big.NewInt(0).SetUint64(gasLimit)).String(), nil
+}
+
+func (contract *ethRedPacketContract) SimulateAction(account base.Account, rpa *RedPacketAction) (*SimulateResult, error) {
+	return contract.SimulateActionWithContext(context.Background(), account, rpa)
+}
 
-	priceInt, ok := big.NewInt(0).SetString(price, 10)
+// SimulateActionWithContext eth_call the action at the latest block and estimate the gas,
+// eth_call doesn't return the logs and the state changes, so the events are empty
+// and the balance changes of the sender are derived from the create action.
+func (contract *ethRedPacketContract) SimulateActionWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*SimulateResult, error) {
+	toAddress, data, value, err := contract.transactionData(ctx, rpa)
+	if err != nil {
+		return nil, err
+	}
+	valueInt, ok := big.NewInt(0).SetString(value, 10)
 	if !ok {
-		return "", errors.New("invalid gas price")
+		return nil, newInvalidParamsError("invalid fee value")
+	}
+
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
 	}
-	limitInt, ok := big.NewInt(0).SetString(gasLimit.Value, 10)
+	to := common.HexToAddress(toAddress)
+	_, err = client.CallContract(ctx, ethereum.CallMsg{
+		From:  common.HexToAddress(account.Address()),
+		To:    &to,
+		Value: valueInt,
+		Data:  data,
+	}, nil)
+	if err != nil {
+		if abort := ethRevertError(err); abort != nil {
+			return failedSimulateResult(abort)
+		}
+		return nil, networkError(err)
+	}
+
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	gasLimit, err := contract.estimateGasLimit(ctx, client, account.Address(), toAddress, data, valueInt, price)
+	if err != nil {
+		return failedSimulateResult(err)
+	}
+	return &SimulateResult{
+		Success:        true,
+		EstimateGasFee: price.Mul(price, big.NewInt(0).SetUint64(gasLimit)).String(),
+		BalanceChanges: ethCreateBalanceChanges(account.Address(), rpa, valueInt),
+		Events:         []*RedPacketEvent{},
+	}, nil
+}
+
+// ethCreateBalanceChanges the sender pay the value in native coin, and the amount of the erc20 red packet
+func ethCreateBalanceChanges(sender string, rpa *RedPacketAction, value *big.Int) []*BalanceChange {
+	if rpa.Method != RPAMethodCreate || rpa.CreateParams == nil {
+		return []*BalanceChange{}
+	}
+	sender = common.HexToAddress(sender).String()
+	changes := []*BalanceChange{{
+		Address:      sender,
+		TokenAddress: common.Address{}.String(),
+		Amount:       big.NewInt(0).Neg(value).String(),
+	}}
+	if token := common.HexToAddress(rpa.CreateParams.TokenAddress); !isEthNativeToken(token) {
+		changes = append(changes, &BalanceChange{
+			Address:      sender,
+			TokenAddress: token.String(),
+			Amount:       "-" + rpa.CreateParams.Amount,
+		})
+	}
+	return changes
+}
+
+func (contract *ethRedPacketContract) QuoteCreate(account base.Account, rpa *RedPacketAction) (*CreateQuote, error) {
+	return contract.QuoteCreateWithContext(context.Background(), account, rpa)
+}
+
+// QuoteCreateWithContext the service fee is charged in the native coin, the erc20 red packet also debit the native coin
+func (contract *ethRedPacketContract) QuoteCreateWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*CreateQuote, error) {
+	return quoteCreate(ctx, contract, account, rpa)
+}
+
+// unestimatedGasFee the default gas limit at the current gas price, it's not an upper bound,
+// the create of a red packet with many opens, the erc20 create or a gas price spike may cost more
+func (contract *ethRedPacketContract) unestimatedGasFee(ctx context.Context) (*big.Int, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	return price.Mul(price, big.NewInt(ethDefaultGasLimit)), nil
+}
+
+func (contract *ethRedPacketContract) quoteTokens(rpa *RedPacketAction) (string, string) {
+	native := common.Address{}.String()
+	return native, native
+}
+
+func (contract *ethRedPacketContract) sameToken(a, b string) bool {
+	return common.HexToAddress(a) == common.HexToAddress(b)
+}
+
+func (contract *ethRedPacketContract) balanceOf(ctx context.Context, owner, token string) (*big.Int, error) {
+	if isEthNativeToken(common.HexToAddress(token)) {
+		client, err := contract.remoteClient()
+		if err != nil {
+			return nil, err
+		}
+		balance, err := client.BalanceAt(ctx, common.HexToAddress(owner), nil)
+		if err != nil {
+			return nil, networkError(err)
+		}
+		return balance, nil
+	}
+	res, err := contract.callAbi(ctx, erc20ABI, common.HexToAddress(token), "balanceOf", common.HexToAddress(owner))
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := res[0].(*big.Int)
 	if !ok {
-		return "", errors.New("invalid gas limit")
+		return nil, newRedPacketDataError("erc20 balance is not uint256")
 	}
+	return balance, nil
+}
 
-	return priceInt.Mul(priceInt, limitInt).String(), nil
+// ethDefaultGasLimit is used when the gas can not be estimated
+const ethDefaultGasLimit = 200000
+
+func (contract *ethRedPacketContract) estimateGasLimit(ctx context.Context, client *ethclient.Client, from, toAddress string, data []byte, value, price *big.Int) (uint64, error) {
+	to := common.HexToAddress(toAddress)
+	gasLimit, err := client.EstimateGas(ctx, ethereum.CallMsg{
+		From:     common.HexToAddress(from),
+		To:       &to,
+		GasPrice: price,
+		Value:    value,
+		Data:     data,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		// the call will revert on chain, other errors fallback to the default gas limit
+		if abort := ethRevertError(err); abort != nil {
+			return 0, abort
+		}
+		return ethDefaultGasLimit, nil
+	}
+	return gasLimit, nil
 }
 
 func (contract *ethRedPacketContract) FetchRedPacketCreationDetail(hash string) (*RedPacketDetail, error) {
-	detail, err := contract.fetchRedPacketCreationDetail(hash)
+	return contract.FetchRedPacketCreationDetailWithContext(context.Background(), hash)
+}
+
+func (contract *ethRedPacketContract) FetchRedPacketCreationDetailWithContext(ctx context.Context, hash string) (*RedPacketDetail, error) {
+	detail, err := contract.fetchRedPacketCreationDetail(ctx, hash)
 	if err != nil {
 		return detail, err
 	}
@@ -104,47 +501,537 @@ func (contract *ethRedPacketContract) FetchRedPacketCreationDetail(hash string)
 		AmountName:        detail.AmountName,
 		RedPacketAmount:   detail.RedPacketAmount,
 		AmountDecimal:     detail.AmountDecimal,
+		PacketId:          detail.PacketId,
+		Abort:             detail.Abort,
 	}, nil
 }
 
+func (contract *ethRedPacketContract) FetchRedPacketOpenDetail(hash string) (*RedPacketOpenDetail, error) {
+	return contract.FetchRedPacketOpenDetailWithContext(context.Background(), hash)
+}
+
+func (contract *ethRedPacketContract) FetchRedPacketOpenDetailWithContext(ctx context.Context, hash string) (*RedPacketOpenDetail, error) {
+	detail, tx, receipt, err := contract.fetchTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	method, params, err := eth.DecodeContractParams(RedPacketABI, tx.Data())
+	if err != nil {
+		return nil, newRedPacketDataError(err.Error())
+	}
+	if method != RPAMethodOpen {
+		return nil, newRedPacketDataError("not open transaction")
+	}
+	id, _ := params[0].(*big.Int)
+	addrs, _ := params[1].([]common.Address)
+	balances, _ := params[2].([]*big.Int)
+	if id == nil || len(addrs) != len(balances) {
+		return nil, newRedPacketDataError("invalid open params")
+	}
+
+	openDetail := &RedPacketOpenDetail{
+		TransactionDetail: detail,
+		ChainName:         ChainTypeEth,
+		PacketId:          id.Int64(),
+		Addresses:         make([]string, len(addrs)),
+		Amounts:           make([]string, len(balances)),
+		Abort:             transactionAbort(detail),
+	}
+	for i := range addrs {
+		openDetail.Addresses[i] = addrs[i].String()
+		openDetail.Amounts[i] = balances[i].String()
+	}
+	detail.Amount = sumAmounts(openDetail.Amounts)
+
+	if infos, err := contract.callContract(ctx, "red_envelop_infos", id); err == nil && len(infos) > 0 {
+		if token, ok := infos[0].(common.Address); ok {
+			openDetail.AmountName, _ = contract.tokenName(ctx, token)
+			openDetail.AmountDecimal, _ = contract.tokenDecimal(ctx, token)
+		}
+	}
+
+	if receipt != nil && receipt.Status == types.ReceiptStatusSuccessful {
+		remainCount, remainBalance, err := contract.remainFromLogs(receipt.Logs)
+		if err != nil {
+			return openDetail, err
+		}
+		openDetail.RemainCount = remainCount
+		openDetail.RemainBalance = remainBalance
+	}
+	return openDetail, nil
+}
+
+func (contract *ethRedPacketContract) FetchRedPacketCloseDetail(hash string) (*RedPacketCloseDetail, error) {
+	return contract.FetchRedPacketCloseDetailWithContext(context.Background(), hash)
+}
+
+func (contract *ethRedPacketContract) FetchRedPacketCloseDetailWithContext(ctx context.Context, hash string) (*RedPacketCloseDetail, error) {
+	detail, tx, receipt, err := contract.fetchTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	method, params, err := eth.DecodeContractParams(RedPacketABI, tx.Data())
+	if err != nil {
+		return nil, newRedPacketDataError(err.Error())
+	}
+	if method != RPAMethodClose {
+		return nil, newRedPacketDataError("not close transaction")
+	}
+	id, _ := params[0].(*big.Int)
+	creator, _ := params[1].(common.Address)
+	if id == nil {
+		return nil, newRedPacketDataError("invalid close params")
+	}
+
+	closeDetail := &RedPacketCloseDetail{
+		TransactionDetail: detail,
+		ChainName:         ChainTypeEth,
+		PacketId:          id.Int64(),
+		Creator:           creator.String(),
+		RefundAmount:      "0",
+		Abort:             transactionAbort(detail),
+	}
+	if receipt == nil || receipt.Status != types.ReceiptStatusSuccessful {
+		return closeDetail, nil
+	}
+
+	token, refund, err := contract.closeRefund(ctx, id, creator, receipt)
+	if err != nil {
+		return closeDetail, err
+	}
+	closeDetail.RefundAmount = refund.String()
+	detail.Amount = closeDetail.RefundAmount
+	if closeDetail.AmountName, err = contract.tokenName(ctx, token); err != nil {
+		return closeDetail, err
+	}
+	if closeDetail.AmountDecimal, err = contract.tokenDecimal(ctx, token); err != nil {
+		return closeDetail, err
+	}
+	return closeDetail, nil
+}
+
+// closeRefund work out the token and the refund of the successful close transaction.
+// The erc20 refund is the Transfer from the contract to the creator in the close logs.
+// Otherwise the refund is the remain balance just before the close, which is the last UpdateRedEnvelop
+// of the earlier transactions in the same block, or the state at the parent block.
+func (contract *ethRedPacketContract) closeRefund(ctx context.Context, id *big.Int, creator common.Address, receipt *types.Receipt) (common.Address, *big.Int, error) {
+	if token, refund := contract.refundFromTransferLogs(receipt.Logs, creator); refund.Sign() > 0 {
+		return token, refund, nil
+	}
+
+	var token *common.Address
+	var remain *big.Int
+	parentBlock := big.NewInt(0).Sub(receipt.BlockNumber, big.NewInt(1))
+	if infos, err := contract.callContractAt(ctx, parentBlock, "red_envelop_infos", id); err == nil && len(infos) >= 3 {
+		infoToken, ok1 := infos[0].(common.Address)
+		infoRemain, ok2 := infos[2].(*big.Int)
+		if ok1 && ok2 {
+			token, remain = &infoToken, infoRemain
+		}
+	}
+	earlier, err := contract.remainBeforeInBlock(ctx, id, receipt)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if earlier != nil {
+		remain = earlier
+	}
+
+	switch {
+	case token != nil:
+		return *token, remain, nil
+	case remain != nil && remain.Sign() > 0:
+		// the erc20 refund has the Transfer log, so it's the native coin refund
+		return common.Address{}, remain, nil
+	default:
+		return common.Address{}, nil, newRedPacketDataError("can't work out the refund of the close, the state of the parent block may be pruned")
+	}
+}
+
+// remainBeforeInBlock the remain balance of the last UpdateRedEnvelop of the red packet
+// in the transactions before the receipt in the same block, nil if there isn't one
+func (contract *ethRedPacketContract) remainBeforeInBlock(ctx context.Context, id *big.Int, receipt *types.Receipt) (*big.Int, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	blockHash := receipt.BlockHash
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		BlockHash: &blockHash,
+		Addresses: []common.Address{common.HexToAddress(contract.address)},
+		Topics:    [][]common.Hash{{redPacketABI.Events["UpdateRedEnvelop"].ID}},
+	})
+	if err != nil {
+		return nil, networkError(err)
+	}
+	var earlier []*types.Log
+	for i := range logs {
+		if logs[i].TxIndex < receipt.TransactionIndex {
+			earlier = append(earlier, &logs[i])
+		}
+	}
+	events, err := contract.updateRedEnvelopEvents(earlier)
+	if err != nil {
+		return nil, err
+	}
+	var remain *big.Int
+	for _, event := range events {
+		if event.PacketId == id.Int64() {
+			remain, _ = big.NewInt(0).SetString(event.RemainBalance, 10)
+		}
+	}
+	return remain, nil
+}
+
 func (contract *ethRedPacketContract) SendTransaction(account base.Account, rpa *RedPacketAction) (string, error) {
-	params, err := contract.packParams(rpa)
+	return contract.SendTransactionWithContext(context.Background(), account, rpa)
+}
+
+func (contract *ethRedPacketContract) SendTransactionWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (string, error) {
+	toAddress, data, value, err := contract.transactionData(ctx, rpa)
 	if err != nil {
 		return "", err
 	}
-	data, err := eth.EncodeContractData(RedPacketABI, rpa.Method, params...)
+	if err = contract.checkCreate(ctx, account.Address(), rpa); err != nil {
+		return "", err
+	}
+
+	valueInt, ok := big.NewInt(0).SetString(value, 10)
+	if !ok {
+		return "", newInvalidParamsError("invalid fee value")
+	}
+	hash, err := contract.sendData(ctx, account, toAddress, data, valueInt)
 	if err != nil {
 		return "", err
 	}
+	if rpa.Method == RPAMethodSetPrepaidFee {
+		contract.watchFeeTransaction(hash)
+	}
+	return hash, nil
+}
+
+// checkCreate the create transaction will revert without enough allowance, don't waste the gas,
+// the fee is checked by transactionData
+func (contract *ethRedPacketContract) checkCreate(ctx context.Context, owner string, rpa *RedPacketAction) error {
+	if rpa.Method != RPAMethodCreate {
+		return nil
+	}
+	approve, err := contract.PrepareCreateWithContext(ctx, owner, rpa)
+	if err != nil {
+		return err
+	}
+	if approve != nil {
+		return ErrInsufficientAllowance
+	}
+	return nil
+}
+
+// ethFeeTransactionWatchDuration the set_prepaid_fee transaction which nobody waits for, or which is dropped,
+// stops blocking the fee cache after it, the transaction is mined or gone by then.
+const ethFeeTransactionWatchDuration = 10 * time.Minute
+
+// watchFeeTransaction stop caching the fee until the set_prepaid_fee transaction is confirmed,
+// the cached fee may be read before the transaction is executed.
+func (contract *ethRedPacketContract) watchFeeTransaction(hash string) {
+	contract.feeCacheLock.Lock()
+	contract.feeCache = make(map[int]ethFeeCacheItem)
+	contract.feeTransactions[common.HexToHash(hash)] = time.Now().Add(ethFeeTransactionWatchDuration)
+	contract.feeCacheLock.Unlock()
+}
+
+// forgetFeeTransaction the set_prepaid_fee transaction is confirmed or replaced, clear the fee cached during it's pending
+func (contract *ethRedPacketContract) forgetFeeTransaction(hash string) {
+	contract.feeCacheLock.Lock()
+	if _, ok := contract.feeTransactions[common.HexToHash(hash)]; ok {
+		delete(contract.feeTransactions, common.HexToHash(hash))
+		contract.feeCache = make(map[int]ethFeeCacheItem)
+	}
+	contract.feeCacheLock.Unlock()
+}
+
+// hasPendingFeeTransaction drop the expired set_prepaid_fee transactions, must be called with the feeCacheLock
+func (contract *ethRedPacketContract) hasPendingFeeTransaction() bool {
+	now := time.Now()
+	for hash, expiredAt := range contract.feeTransactions {
+		if now.After(expiredAt) {
+			delete(contract.feeTransactions, hash)
+		}
+	}
+	return len(contract.feeTransactions) > 0
+}
+
+// isSetPrepaidFeeData the transaction calls set_prepaid_fee of the red packet contract
+func (contract *ethRedPacketContract) isSetPrepaidFeeData(to *common.Address, data []byte) bool {
+	if to == nil || *to != common.HexToAddress(contract.address) {
+		return false
+	}
+	method, _, err := eth.DecodeContractParams(RedPacketABI, data)
+	return err == nil && method == RPAMethodSetPrepaidFee
+}
+
+func (contract *ethRedPacketContract) BuildUnsignedTransaction(sender string, rpa *RedPacketAction) (*UnsignedTransaction, error) {
+	return contract.BuildUnsignedTransactionWithContext(context.Background(), sender, rpa)
+}
+
+// BuildUnsignedTransactionWithContext build the legacy transaction with the pending nonce of sender,
+// sign the SigningMessage and set the signature by types.Transaction.WithSignature with the EIP155 signer.
+func (contract *ethRedPacketContract) BuildUnsignedTransactionWithContext(ctx context.Context, sender string, rpa *RedPacketAction) (*UnsignedTransaction, error) {
+	if !common.IsHexAddress(sender) {
+		return nil, newInvalidParamsError("invalid sender address")
+	}
+	toAddress, data, value, err := contract.transactionData(ctx, rpa)
+	if err != nil {
+		return nil, err
+	}
+	if err = contract.checkCreate(ctx, sender, rpa); err != nil {
+		return nil, err
+	}
+	valueInt, ok := big.NewInt(0).SetString(value, 10)
+	if !ok {
+		return nil, newInvalidParamsError("invalid fee value")
+	}
 
-	value, err := contract.EstimateFee(rpa)
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	nonce, err := client.PendingNonceAt(ctx, common.HexToAddress(sender))
+	if err != nil {
+		return nil, networkError(err)
+	}
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	gasLimit, err := contract.estimateGasLimit(ctx, client, sender, toAddress, data, valueInt, price)
+	if err != nil {
+		return nil, err
+	}
+
+	to := common.HexToAddress(toAddress)
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: price,
+		Gas:      gasLimit,
+		To:       &to,
+		Value:    valueInt,
+		Data:     data,
+	})
+	txBytes, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &UnsignedTransaction{
+		ChainName:      ChainTypeEth,
+		Sender:         common.HexToAddress(sender).String(),
+		Data:           txBytes,
+		SigningMessage: types.NewEIP155Signer(chainId).Hash(tx).Bytes(),
+		Nonce:          nonce,
+		GasLimit:       gasLimit,
+		GasPrice:       price.String(),
+		ChainId:        chainId.String(),
+		EstimateGasFee: big.NewInt(0).Mul(price, big.NewInt(0).SetUint64(gasLimit)).String(),
+	}, nil
+}
+
+func (contract *ethRedPacketContract) SubmitSignedTransaction(signedTx *SignedTransaction) (string, error) {
+	return contract.SubmitSignedTransactionWithContext(context.Background(), signedTx)
+}
+
+func (contract *ethRedPacketContract) SubmitSignedTransactionWithContext(ctx context.Context, signedTx *SignedTransaction) (string, error) {
+	if signedTx == nil || len(signedTx.Data) == 0 {
+		return "", newInvalidParamsError("invalid signed transaction")
+	}
+	tx := &types.Transaction{}
+	if err := tx.UnmarshalBinary(signedTx.Data); err != nil {
+		return "", newInvalidParamsError("invalid signed transaction: %v", err)
+	}
+	client, err := contract.remoteClient()
 	if err != nil {
 		return "", err
 	}
+	if err = client.SendTransaction(ctx, tx); err != nil {
+		return "", networkError(err)
+	}
+	if contract.isSetPrepaidFeeData(tx.To(), tx.Data()) {
+		contract.watchFeeTransaction(tx.Hash().String())
+	}
+	return tx.Hash().String(), nil
+}
+
+func (contract *ethRedPacketContract) PrepareCreate(owner string, rpa *RedPacketAction) (*RedPacketAction, error) {
+	return contract.PrepareCreateWithContext(context.Background(), owner, rpa)
+}
 
-	return contract.chain.SubmitTransactionData(account, contract.address, data, value)
+// PrepareCreateWithContext return the approve action when the allowance of owner is less than the red packet amount,
+// the non-zero allowance is approved to 0 first, call it again after the approve is confirmed. The native coin does not need approve.
+func (contract *ethRedPacketContract) PrepareCreateWithContext(ctx context.Context, owner string, rpa *RedPacketAction) (*RedPacketAction, error) {
+	if rpa.Method != RPAMethodCreate || rpa.CreateParams == nil {
+		return nil, newInvalidParamsError("invalid create params")
+	}
+	if rpa.CreateParams.TokenAddress != "" && !common.IsHexAddress(rpa.CreateParams.TokenAddress) {
+		return nil, newInvalidParamsError("invalid token address %v", rpa.CreateParams.TokenAddress)
+	}
+	token := common.HexToAddress(rpa.CreateParams.TokenAddress)
+	if isEthNativeToken(token) {
+		return nil, nil
+	}
+	amount, ok := big.NewInt(0).SetString(rpa.CreateParams.Amount, 10)
+	if !ok {
+		return nil, newInvalidParamsError("invalid red packet amount %v", rpa.CreateParams.Amount)
+	}
+	allowance, err := contract.allowance(ctx, token, common.HexToAddress(owner))
+	if err != nil {
+		return nil, err
+	}
+	if allowance.Cmp(amount) >= 0 {
+		return nil, nil
+	}
+	if allowance.Sign() > 0 {
+		// USDT like tokens revert the approve from a non-zero allowance to another non-zero one
+		return NewRedPacketActionApprove(rpa.CreateParams.TokenAddress, "0")
+	}
+	return NewRedPacketActionApprove(rpa.CreateParams.TokenAddress, amount.String())
+}
+
+func (contract *ethRedPacketContract) FetchRedPacketState(tokenAddress string, packetId int64, packetObjectId string) (*RedPacketState, error) {
+	return contract.FetchRedPacketStateWithContext(context.Background(), tokenAddress, packetId, packetObjectId)
+}
+
+func (contract *ethRedPacketContract) FetchRedPacketStateWithContext(ctx context.Context, tokenAddress string, packetId int64, packetObjectId string) (*RedPacketState, error) {
+	id := big.NewInt(packetId)
+	infos, err := contract.callContract(ctx, "red_envelop_infos", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) < 3 {
+		return nil, newRedPacketDataError(fmt.Sprintf("invalid red_envelop_infos outputs, len %d", len(infos)))
+	}
+	valid, err := contract.callContract(ctx, "is_valid", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(valid) < 1 {
+		return nil, newRedPacketDataError("invalid is_valid outputs")
+	}
+	token, _ := infos[0].(common.Address)
+	remainCount, _ := infos[1].(*big.Int)
+	remainBalance, _ := infos[2].(*big.Int)
+	if remainCount == nil || remainBalance == nil {
+		return nil, newRedPacketDataError("invalid red_envelop_infos outputs")
+	}
+	isValid, _ := valid[0].(bool)
+	if !isValid && remainCount.Sign() > 0 {
+		// the exhausted red packet has no remain count, the remain of the invalid one is waiting to be refunded
+		return nil, newRedPacketError(ErrorCodePacketClosed, fmt.Sprintf("red packet %d is closed", packetId))
+	}
+	return &RedPacketState{
+		TokenAddress:  token.String(),
+		RemainCount:   remainCount.Int64(),
+		RemainBalance: remainBalance.String(),
+		Valid:         isValid,
+	}, nil
+}
+
+func (contract *ethRedPacketContract) WaitForConfirmation(ctx context.Context, hash string, opts *ConfirmOptions) (*RedPacketConfirmation, error) {
+	return waitForConfirmation(ctx, contract, contract, hash, opts)
+}
+
+// transactionStatus the transaction in the mempool is pending, the receipt may be not ready just after it's mined
+func (contract *ethRedPacketContract) transactionStatus(ctx context.Context, hash string) (*base.TransactionDetail, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	_, isPending, err := client.TransactionByHash(ctx, common.HexToHash(hash))
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, errTransactionNotFound
+		}
+		return nil, networkError(err)
+	}
+	if isPending {
+		return &base.TransactionDetail{HashString: hash, Status: base.TransactionStatusPending}, nil
+	}
+	detail, _, _, err := contract.fetchTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if detail.Status != base.TransactionStatusPending {
+		contract.forgetFeeTransaction(hash)
+	}
+	return detail, nil
+}
+
+// transactionData return the to address, data and value of the action transaction
+func (contract *ethRedPacketContract) transactionData(ctx context.Context, rpa *RedPacketAction) (string, []byte, string, error) {
+	if rpa.Method == RPAMethodApprove {
+		if rpa.ApproveParams == nil {
+			return "", nil, "", newInvalidParamsError("invalid approve params")
+		}
+		amount, ok := big.NewInt(0).SetString(rpa.ApproveParams.Amount, 10)
+		if !ok {
+			return "", nil, "", newInvalidParamsError("invalid approve amount %v", rpa.ApproveParams.Amount)
+		}
+		data, err := erc20ABI.Pack("approve", common.HexToAddress(contract.address), amount)
+		if err != nil {
+			return "", nil, "", err
+		}
+		return rpa.ApproveParams.TokenAddress, data, "0", nil
+	}
+
+	params, err := contract.packParams(rpa)
+	if err != nil {
+		return "", nil, "", err
+	}
+	data, err := eth.EncodeContractData(RedPacketABI, rpa.Method, params...)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if rpa.Method != RPAMethodCreate {
+		return contract.address, data, "0", nil
+	}
+	count := rpa.CreateParams.Count
+	fee, cached, err := contract.createFee(ctx, count)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if cached {
+		if err = contract.checkPrepaidFee(ctx, count, fee); err != nil {
+			return "", nil, "", err
+		}
+	}
+	if isEthNativeToken(params[0].(common.Address)) {
+		// the native coin red packet amount is sent with the fee
+		fee = big.NewInt(0).Add(fee, params[2].(*big.Int))
+	}
+	return contract.address, data, fee.String(), nil
 }
 
 func (contract *ethRedPacketContract) packParams(rpa *RedPacketAction) ([]interface{}, error) {
 	switch rpa.Method {
 	case RPAMethodCreate:
 		if rpa.CreateParams == nil {
-			return nil, errors.New("invalid create params")
+			return nil, newInvalidParamsError("invalid create params")
 		}
 		addr := common.HexToAddress(rpa.CreateParams.TokenAddress)
 		c := big.NewInt(int64(rpa.CreateParams.Count))
 		a, ok := big.NewInt(0).SetString(rpa.CreateParams.Amount, 10)
 		if !ok {
-			return nil, fmt.Errorf("invalid red packet amount %v", rpa.CreateParams.Amount)
+			return nil, newInvalidParamsError("invalid red packet amount %v", rpa.CreateParams.Amount)
 		}
 		return []interface{}{addr, c, a}, nil
 	case RPAMethodOpen:
 		if rpa.OpenParams == nil {
-			return nil, errors.New("invalid open params")
+			return nil, newInvalidParamsError("invalid open params")
 		}
 		id := big.NewInt(rpa.OpenParams.PacketId)
 		if len(rpa.OpenParams.Addresses) != len(rpa.OpenParams.Amounts) {
-			return nil, fmt.Errorf("the number of opened addresses is not the same as the amount")
+			return nil, newInvalidParamsError("the number of opened addresses is not the same as the amount")
 		}
 		addrs := make([]common.Address, len(rpa.OpenParams.Addresses))
 		for index, address := range rpa.OpenParams.Addresses {
@@ -154,35 +1041,46 @@ func (contract *ethRedPacketContract) packParams(rpa *RedPacketAction) ([]interf
 		for index, amount := range rpa.OpenParams.Amounts {
 			aInt, ok := big.NewInt(0).SetString(amount, 10)
 			if !ok {
-				return nil, fmt.Errorf("invalid red packet amount %v", amount)
+				return nil, newInvalidParamsError("invalid red packet amount %v", amount)
 			}
 			amountInts[index] = aInt
 		}
 		return []interface{}{id, addrs, amountInts}, nil
 	case RPAMethodClose:
 		if rpa.CloseParams == nil {
-			return nil, errors.New("invalid close params")
+			return nil, newInvalidParamsError("invalid close params")
 		}
 		id := big.NewInt(rpa.CloseParams.PacketId)
 		addr := common.HexToAddress(rpa.CloseParams.Creator)
 		return []interface{}{id, addr}, nil
+	case RPAMethodSetAdmin, RPAMethodSetBeneficiary, RPAMethodTransferOwnership:
+		if rpa.AdminParams == nil || !common.IsHexAddress(rpa.AdminParams.Address) {
+			return nil, newInvalidParamsError("invalid admin params")
+		}
+		return []interface{}{common.HexToAddress(rpa.AdminParams.Address)}, nil
+	case RPAMethodSetPrepaidFee:
+		if rpa.AdminParams == nil {
+			return nil, newInvalidParamsError("invalid admin params")
+		}
+		fee, ok := big.NewInt(0).SetString(rpa.AdminParams.Fee, 10)
+		if !ok {
+			return nil, newInvalidParamsError("invalid prepaid fee %v", rpa.AdminParams.Fee)
+		}
+		return []interface{}{fee}, nil
+	case RPAMethodRenounceOwnership:
+		return []interface{}{}, nil
 	default:
-		return nil, errors.New("invalid method")
+		return nil, newInvalidParamsError("invalid method")
 	}
 }
 
-func (contract *ethRedPacketContract) fetchRedPacketCreationDetail(hash string) (*RedPacketDetail, error) {
-	chain, err := contract.chain.GetEthChain()
+func (contract *ethRedPacketContract) fetchRedPacketCreationDetail(ctx context.Context, hash string) (*RedPacketDetail, error) {
+	detail, tx, receipt, err := contract.fetchTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
-
-	detail, msg, err := chain.FetchTransactionDetail(hash)
-	if err != nil {
-		return nil, err
-	}
-	redDetail := &RedPacketDetail{detail, "", 0, "", ""}
-	if data := msg.Data(); len(data) > 0 {
+	redDetail := &RedPacketDetail{TransactionDetail: detail, Abort: transactionAbort(detail)}
+	if data := tx.Data(); len(data) > 0 {
 		method, params, err_ := eth.DecodeContractParams(RedPacketABI, data)
 		if err_ != nil {
 			return redDetail, newRedPacketDataError(err_.Error())
@@ -196,16 +1094,278 @@ func (contract *ethRedPacketContract) fetchRedPacketCreationDetail(hash string)
 			if !ok {
 				valueInt = big.NewInt(0)
 			}
+			erc20Address := params[0].(common.Address)
+			if isEthNativeToken(erc20Address) {
+				// the value contains the native coin red packet amount
+				valueInt.Sub(valueInt, params[2].(*big.Int))
+			}
 			feeInt = feeInt.Add(feeInt, valueInt)
 
 			redDetail.EstimateFees = feeInt.String()
 			redDetail.Amount = params[2].(*big.Int).String()
 			redDetail.RedPacketAmount = redDetail.Amount
-			erc20Address := params[0].(common.Address).String()
-			redDetail.AmountName, _ = chain.TokenName(erc20Address)
-			redDetail.AmountDecimal, _ = chain.TokenDecimal(erc20Address)
+			redDetail.AmountName, _ = contract.tokenName(ctx, erc20Address)
+			redDetail.AmountDecimal, _ = contract.tokenDecimal(ctx, erc20Address)
+			if receipt != nil && receipt.Status == types.ReceiptStatusSuccessful {
+				packetId, err := contract.packetIdFromLogs(receipt.Logs)
+				if err != nil {
+					return redDetail, err
+				}
+				redDetail.PacketId = packetId
+			}
 		}
 	}
 
 	return redDetail, nil
 }
+
+// remainFromLogs get the remain count and balance from UpdateRedEnvelop event
+func (contract *ethRedPacketContract) remainFromLogs(logs []*types.Log) (int64, string, error) {
+	events, err := contract.updateRedEnvelopEvents(logs)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(events) == 0 {
+		return 0, "", newRedPacketDataError("not found UpdateRedEnvelop event")
+	}
+	return events[0].RemainCount, events[0].RemainBalance, nil
+}
+
+// updateRedEnvelopEvents decode the UpdateRedEnvelop events of the red packet contract in the logs, they are emitted by open
+func (contract *ethRedPacketContract) updateRedEnvelopEvents(logs []*types.Log) ([]*RedPacketEvent, error) {
+	event := redPacketABI.Events["UpdateRedEnvelop"]
+	contractAddress := common.HexToAddress(contract.address)
+	var events []*RedPacketEvent
+	for _, log := range logs {
+		if log.Address != contractAddress || len(log.Topics) == 0 || log.Topics[0] != event.ID {
+			continue
+		}
+		values, err := event.Inputs.Unpack(log.Data)
+		if err != nil {
+			return nil, newRedPacketDataError(err.Error())
+		}
+		id, ok := values[0].(*big.Int)
+		if !ok {
+			return nil, newRedPacketDataError("UpdateRedEnvelop id is not uint256")
+		}
+		remainCount, ok := values[1].(*big.Int)
+		if !ok {
+			return nil, newRedPacketDataError("UpdateRedEnvelop remain_count is not uint256")
+		}
+		remainBalance, ok := values[2].(*big.Int)
+		if !ok {
+			return nil, newRedPacketDataError("UpdateRedEnvelop remain_balance is not uint256")
+		}
+		events = append(events, &RedPacketEvent{
+			Method:        RPAMethodOpen,
+			PacketId:      id.Int64(),
+			RemainCount:   remainCount.Int64(),
+			RemainBalance: remainBalance.String(),
+		})
+	}
+	return events, nil
+}
+
+// refundFromTransferLogs sum the erc20 Transfer from the contract to creator
+func (contract *ethRedPacketContract) refundFromTransferLogs(logs []*types.Log, creator common.Address) (common.Address, *big.Int) {
+	event := erc20ABI.Events["Transfer"]
+	contractAddress := common.HexToAddress(contract.address)
+	var token common.Address
+	refund := big.NewInt(0)
+	for _, log := range logs {
+		if len(log.Topics) != 3 || log.Topics[0] != event.ID {
+			continue
+		}
+		if common.BytesToAddress(log.Topics[1].Bytes()) != contractAddress || common.BytesToAddress(log.Topics[2].Bytes()) != creator {
+			continue
+		}
+		values, err := event.Inputs.NonIndexed().Unpack(log.Data)
+		if err != nil || len(values) == 0 {
+			continue
+		}
+		if value, ok := values[0].(*big.Int); ok {
+			token = log.Address
+			refund.Add(refund, value)
+		}
+	}
+	return token, refund
+}
+
+// packetIdFromLogs get the id of created red packet from NewRedEnvelop event
+func (contract *ethRedPacketContract) packetIdFromLogs(logs []*types.Log) (int64, error) {
+	event := redPacketABI.Events["NewRedEnvelop"]
+	contractAddress := common.HexToAddress(contract.address)
+	for _, log := range logs {
+		if log.Address != contractAddress || len(log.Topics) == 0 || log.Topics[0] != event.ID {
+			continue
+		}
+		values, err := event.Inputs.Unpack(log.Data)
+		if err != nil {
+			return 0, newRedPacketDataError(err.Error())
+		}
+		id, ok := values[0].(*big.Int)
+		if !ok {
+			return 0, newRedPacketDataError("NewRedEnvelop id is not uint256")
+		}
+		return id.Int64(), nil
+	}
+	return 0, newRedPacketDataError("not found NewRedEnvelop event")
+}
+
+func (contract *ethRedPacketContract) remoteClient() (*ethclient.Client, error) {
+	chain, err := contract.chain.GetEthChain()
+	if err != nil {
+		return nil, networkError(err)
+	}
+	return chain.RemoteRpcClient, nil
+}
+
+// fetchTransaction get the transaction with it's receipt, the receipt is nil when the transaction is pending
+func (contract *ethRedPacketContract) fetchTransaction(ctx context.Context, hash string) (*base.TransactionDetail, *types.Transaction, *types.Receipt, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tx, isPending, err := client.TransactionByHash(ctx, common.HexToHash(hash))
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, nil, nil, newRedPacketDataError(err.Error())
+		}
+		return nil, nil, nil, networkError(err)
+	}
+
+	detail := &base.TransactionDetail{
+		HashString: hash,
+		Amount:     tx.Value().String(),
+		Status:     base.TransactionStatusPending,
+	}
+	if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+		detail.FromAddress = from.String()
+	}
+	if to := tx.To(); to != nil {
+		detail.ToAddress = to.String()
+	}
+	if isPending {
+		return detail, tx, nil, nil
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		return nil, nil, nil, networkError(err)
+	}
+	header, err := client.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, nil, nil, networkError(err)
+	}
+	gasPrice := tx.GasPrice()
+	if header.BaseFee != nil && tx.Type() == types.DynamicFeeTxType {
+		gasPrice = big.NewInt(0).Add(header.BaseFee, tx.GasTipCap())
+		if gasPrice.Cmp(tx.GasFeeCap()) > 0 {
+			gasPrice = tx.GasFeeCap()
+		}
+	}
+	detail.EstimateFees = big.NewInt(0).Mul(gasPrice, big.NewInt(0).SetUint64(receipt.GasUsed)).String()
+	detail.FinishTimestamp = int64(header.Time)
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		detail.Status = base.TransactionStatusSuccess
+	} else {
+		detail.Status = base.TransactionStatusFailure
+		detail.FailureMessage = contract.revertReason(ctx, client, tx, detail.FromAddress, receipt.BlockNumber)
+	}
+	return detail, tx, receipt, nil
+}
+
+// revertReason replay the failed transaction at the parent block to get the revert reason,
+// the receipt doesn't contain the reason. The transactions before it in the same block are not replayed,
+// so the replay may not revert, the plain "execution reverted" is returned in that case.
+func (contract *ethRedPacketContract) revertReason(ctx context.Context, client *ethclient.Client, tx *types.Transaction, from string, blockNumber *big.Int) string {
+	reason := "execution reverted"
+	if blockNumber == nil || blockNumber.Sign() <= 0 {
+		return reason
+	}
+	_, err := client.CallContract(ctx, ethereum.CallMsg{
+		From:  common.HexToAddress(from),
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}, big.NewInt(0).Sub(blockNumber, big.NewInt(1)))
+	if err == nil {
+		return reason
+	}
+	if abort := ethRevertError(err); abort != nil && abort.Reason != reason {
+		return reason + ": " + abort.Reason
+	}
+	return reason
+}
+
+// callContract call the view function of the red packet contract
+func (contract *ethRedPacketContract) callContract(ctx context.Context, method string, params ...interface{}) ([]interface{}, error) {
+	return contract.callContractAt(ctx, nil, method, params...)
+}
+
+// callContractAt call the view function of the red packet contract at the block, nil block is the latest
+func (contract *ethRedPacketContract) callContractAt(ctx context.Context, blockNumber *big.Int, method string, params ...interface{}) ([]interface{}, error) {
+	return contract.callAbiAt(ctx, redPacketABI, common.HexToAddress(contract.address), blockNumber, method, params...)
+}
+
+func (contract *ethRedPacketContract) callAbi(ctx context.Context, contractAbi abi.ABI, to common.Address, method string, params ...interface{}) ([]interface{}, error) {
+	return contract.callAbiAt(ctx, contractAbi, to, nil, method, params...)
+}
+
+func (contract *ethRedPacketContract) callAbiAt(ctx context.Context, contractAbi abi.ABI, to common.Address, blockNumber *big.Int, method string, params ...interface{}) ([]interface{}, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	data, err := contractAbi.Pack(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, blockNumber)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	return contractAbi.Unpack(method, output)
+}
+
+// isEthNativeToken the zero token address is the native coin of the chain
+func isEthNativeToken(token common.Address) bool {
+	return token == common.Address{}
+}
+
+func (contract *ethRedPacketContract) allowance(ctx context.Context, token common.Address, owner common.Address) (*big.Int, error) {
+	res, err := contract.callAbi(ctx, erc20ABI, token, "allowance", owner, common.HexToAddress(contract.address))
+	if err != nil {
+		return nil, err
+	}
+	allowance, ok := res[0].(*big.Int)
+	if !ok {
+		return nil, newRedPacketDataError("allowance is not uint256")
+	}
+	return allowance, nil
+}
+
+func (contract *ethRedPacketContract) tokenName(ctx context.Context, token common.Address) (string, error) {
+	if isEthNativeToken(token) {
+		return "", nil
+	}
+	res, err := contract.callAbi(ctx, erc20ABI, token, "name")
+	if err != nil {
+		return "", err
+	}
+	name, _ := res[0].(string)
+	return name, nil
+}
+
+func (contract *ethRedPacketContract) tokenDecimal(ctx context.Context, token common.Address) (int16, error) {
+	if isEthNativeToken(token) {
+		return 18, nil
+	}
+	res, err := contract.callAbi(ctx, erc20ABI, token, "decimals")
+	if err != nil {
+		return 0, err
+	}
+	decimal, _ := res[0].(uint8)
+	return int16(decimal), nil
+}
@@ -0,0 +1,384 @@
+package redpacket
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// ethReplacePriceBump the percent of the gas price bump to replace the pending transaction, same as geth txpool
+	ethReplacePriceBump = 10
+	// ethCancelGasLimit the gas of the plain transfer to cancel the pending transaction
+	ethCancelGasLimit = 21000
+	// ethMaxPendingTransactions prune the confirmed transactions when the tracked ones exceed it
+	ethMaxPendingTransactions = 256
+)
+
+// EthNonceManager track the nonces of the eth senders locally, so the transactions sent concurrently
+// by the same account have continuous nonces without waiting the previous ones confirmed.
+// It's safe for concurrent use and can be shared by the contracts on the same chain with ContractConfig.EthNonceManager.
+//
+// The nonce is synced from the chain at the first send and after any failed send,
+// call Reset when the transactions of the account are sent by others.
+type EthNonceManager struct {
+	lock     sync.Mutex
+	accounts map[common.Address]*ethNonceAccount
+}
+
+type ethNonceAccount struct {
+	lock    sync.Mutex // held while signing and sending, so the nonces are taken in order without gaps
+	synced  bool
+	next    uint64
+	pending map[common.Hash]*types.Transaction // the sent transactions, to replace or cancel them
+}
+
+func NewEthNonceManager() *EthNonceManager {
+	return &EthNonceManager{accounts: make(map[common.Address]*ethNonceAccount)}
+}
+
+// Reset drop the local nonce of the address, it will be synced from the chain at the next send
+func (m *EthNonceManager) Reset(address string) {
+	account := m.account(common.HexToAddress(address))
+	account.lock.Lock()
+	account.synced = false
+	account.lock.Unlock()
+}
+
+func (m *EthNonceManager) account(address common.Address) *ethNonceAccount {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	account, ok := m.accounts[address]
+	if !ok {
+		account = &ethNonceAccount{pending: make(map[common.Hash]*types.Transaction)}
+		m.accounts[address] = account
+	}
+	return account
+}
+
+// send sign the transaction with the next nonce of the sender and send it, the nonce is taken only if it's sent.
+// The nonce is synced after the failure, and the nonce conflict is retried once with the synced nonce.
+func (m *EthNonceManager) send(ctx context.Context, client *ethclient.Client, from common.Address, sign func(nonce uint64) (*types.Transaction, error)) (*types.Transaction, error) {
+	account := m.account(from)
+	account.lock.Lock()
+	defer account.lock.Unlock()
+
+	for retried := false; ; retried = true {
+		if !account.synced {
+			if err := account.sync(ctx, client, from); err != nil {
+				return nil, err
+			}
+		}
+		tx, err := sign(account.next)
+		if err != nil {
+			return nil, err
+		}
+		if err = client.SendTransaction(ctx, tx); err != nil && !isEthKnownTransaction(err) {
+			// the local nonce may be stale, the transaction may be sent by others or dropped from the mempool
+			account.synced = false
+			if retried || !isEthNonceConflict(err) {
+				return nil, networkError(err)
+			}
+			continue
+		}
+		account.next++
+		account.pending[tx.Hash()] = tx
+		if len(account.pending) > ethMaxPendingTransactions {
+			account.prune(ctx, client, from)
+		}
+		return tx, nil
+	}
+}
+
+// replace sign the transaction with the nonce of the pending one and send it, the pending one is replaced if it's sent
+func (m *EthNonceManager) replace(ctx context.Context, client *ethclient.Client, from common.Address, pending *types.Transaction, sign func(nonce uint64) (*types.Transaction, error)) (*types.Transaction, error) {
+	account := m.account(from)
+	account.lock.Lock()
+	defer account.lock.Unlock()
+
+	tx, err := sign(pending.Nonce())
+	if err != nil {
+		return nil, err
+	}
+	if err = client.SendTransaction(ctx, tx); err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "nonce too low") {
+			// the pending one or another transaction with the nonce is confirmed
+			account.synced = false
+			delete(account.pending, pending.Hash())
+			return nil, newInvalidParamsError("the nonce %v is already confirmed", pending.Nonce())
+		}
+		if strings.Contains(strings.ToLower(err.Error()), "replacement transaction underpriced") {
+			// retry with the same price will be rejected again, the node may require a higher bump
+			return nil, &RedPacketError{Code: ErrorCodeFeeMismatch, Message: "the gas price of the replacement is too low", Err: err}
+		}
+		return nil, networkError(err)
+	}
+	delete(account.pending, pending.Hash())
+	account.pending[tx.Hash()] = tx
+	return tx, nil
+}
+
+// pendingTransaction the tracked transaction of the sender by hash
+func (m *EthNonceManager) pendingTransaction(from common.Address, hash common.Hash) (*types.Transaction, bool) {
+	account := m.account(from)
+	account.lock.Lock()
+	defer account.lock.Unlock()
+	tx, ok := account.pending[hash]
+	return tx, ok
+}
+
+// sync the pending nonce of the chain includes the transactions in the mempool
+func (account *ethNonceAccount) sync(ctx context.Context, client *ethclient.Client, from common.Address) error {
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return networkError(err)
+	}
+	account.next = nonce
+	account.synced = true
+	account.prune(ctx, client, from)
+	return nil
+}
+
+// prune drop the tracked transactions which nonces are confirmed, they can't be replaced anymore
+func (account *ethNonceAccount) prune(ctx context.Context, client *ethclient.Client, from common.Address) {
+	confirmed, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return
+	}
+	for hash, tx := range account.pending {
+		if tx.Nonce() < confirmed {
+			delete(account.pending, hash)
+		}
+	}
+}
+
+// isEthNonceConflict the nonce is used by the confirmed or pending transaction
+func isEthNonceConflict(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "nonce too low") || strings.Contains(message, "replacement transaction underpriced")
+}
+
+// isEthKnownTransaction the same transaction is already in the mempool, e.g. the retry of a timeout send
+func isEthKnownTransaction(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "already known")
+}
+
+// ethLegacySigner sign the legacy transactions of the account locally with the EIP155 signer
+type ethLegacySigner struct {
+	key    *ecdsa.PrivateKey
+	from   common.Address
+	signer types.Signer
+}
+
+func newEthLegacySigner(ctx context.Context, client *ethclient.Client, account base.Account) (*ethLegacySigner, error) {
+	privateKey, err := account.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.ToECDSA(privateKey)
+	if err != nil {
+		return nil, newInvalidParamsError("invalid eth private key")
+	}
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	return &ethLegacySigner{
+		key:    key,
+		from:   crypto.PubkeyToAddress(key.PublicKey),
+		signer: types.NewEIP155Signer(chainId),
+	}, nil
+}
+
+func (s *ethLegacySigner) sign(nonce uint64, price *big.Int, gasLimit uint64, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	return types.SignTx(types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: price,
+		Gas:      gasLimit,
+		To:       &to,
+		Value:    value,
+		Data:     data,
+	}), s.signer, s.key)
+}
+
+// EthTransactionReplacer replace or cancel the pending transaction of the account at the same nonce,
+// the contract returned by NewEthRedPacketContract can be asserted to it.
+// The gas price is bumped by 10% at least, the replacement is also tracked by the ContractConfig.EthNonceManager.
+type EthTransactionReplacer interface {
+	ReplaceTransaction(account base.Account, hash string, rpa *RedPacketAction) (string, error)
+	CancelTransaction(account base.Account, hash string) (string, error)
+
+	ReplaceTransactionWithContext(ctx context.Context, account base.Account, hash string, rpa *RedPacketAction) (string, error)
+	CancelTransactionWithContext(ctx context.Context, account base.Account, hash string) (string, error)
+}
+
+// nonces the configured nonce manager, or a new one which only tracks the nonces of this call
+func (contract *ethRedPacketContract) nonces() *EthNonceManager {
+	if contract.nonceManager != nil {
+		return contract.nonceManager
+	}
+	return NewEthNonceManager()
+}
+
+// sendData send the transaction with the nonce of the configured nonce manager, or by wallet-SDK without it
+func (contract *ethRedPacketContract) sendData(ctx context.Context, account base.Account, toAddress string, data []byte, value *big.Int) (string, error) {
+	if contract.nonceManager != nil {
+		return contract.sendWithNonce(ctx, account, toAddress, data, value)
+	}
+	// wallet-SDK submit does not accept context, give up before the transaction is broadcast
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	hash, err := contract.chain.SubmitTransactionData(account, toAddress, data, value.String())
+	if err != nil {
+		return "", networkError(err)
+	}
+	return hash, nil
+}
+
+// sendWithNonce sign the transaction locally and send it with the nonce of the nonce manager
+func (contract *ethRedPacketContract) sendWithNonce(ctx context.Context, account base.Account, toAddress string, data []byte, value *big.Int) (string, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return "", err
+	}
+	signer, err := newEthLegacySigner(ctx, client, account)
+	if err != nil {
+		return "", err
+	}
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return "", networkError(err)
+	}
+	gasLimit, err := contract.estimateGasLimit(ctx, client, signer.from.String(), toAddress, data, value, price)
+	if err != nil {
+		return "", err
+	}
+	tx, err := contract.nonces().send(ctx, client, signer.from, func(nonce uint64) (*types.Transaction, error) {
+		return signer.sign(nonce, price, gasLimit, common.HexToAddress(toAddress), value, data)
+	})
+	if err != nil {
+		return "", err
+	}
+	return tx.Hash().String(), nil
+}
+
+func (contract *ethRedPacketContract) ReplaceTransaction(account base.Account, hash string, rpa *RedPacketAction) (string, error) {
+	return contract.ReplaceTransactionWithContext(context.Background(), account, hash, rpa)
+}
+
+// ReplaceTransactionWithContext send the action with the nonce of the pending transaction, e.g. resend the stuck open with a higher gas price
+func (contract *ethRedPacketContract) ReplaceTransactionWithContext(ctx context.Context, account base.Account, hash string, rpa *RedPacketAction) (string, error) {
+	toAddress, data, value, err := contract.transactionData(ctx, rpa)
+	if err != nil {
+		return "", err
+	}
+	if err = contract.checkCreate(ctx, account.Address(), rpa); err != nil {
+		return "", err
+	}
+	valueInt, ok := big.NewInt(0).SetString(value, 10)
+	if !ok {
+		return "", newInvalidParamsError("invalid fee value")
+	}
+	to := common.HexToAddress(toAddress)
+	return contract.replaceTransaction(ctx, account, hash, func(client *ethclient.Client, from common.Address, price *big.Int) (uint64, error) {
+		return contract.estimateGasLimit(ctx, client, from.String(), toAddress, data, valueInt, price)
+	}, to, valueInt, data)
+}
+
+func (contract *ethRedPacketContract) CancelTransaction(account base.Account, hash string) (string, error) {
+	return contract.CancelTransactionWithContext(context.Background(), account, hash)
+}
+
+// CancelTransactionWithContext send the empty transfer to self with the nonce of the pending transaction
+func (contract *ethRedPacketContract) CancelTransactionWithContext(ctx context.Context, account base.Account, hash string) (string, error) {
+	to := common.HexToAddress(account.Address())
+	return contract.replaceTransaction(ctx, account, hash, func(*ethclient.Client, common.Address, *big.Int) (uint64, error) {
+		return ethCancelGasLimit, nil
+	}, to, big.NewInt(0), nil)
+}
+
+func (contract *ethRedPacketContract) replaceTransaction(ctx context.Context, account base.Account, hash string,
+	gasLimit func(client *ethclient.Client, from common.Address, price *big.Int) (uint64, error),
+	to common.Address, value *big.Int, data []byte) (string, error) {
+	client, err := contract.remoteClient()
+	if err != nil {
+		return "", err
+	}
+	signer, err := newEthLegacySigner(ctx, client, account)
+	if err != nil {
+		return "", err
+	}
+	nonces := contract.nonces()
+	pending, err := contract.pendingTransaction(ctx, client, nonces, signer, common.HexToHash(hash))
+	if err != nil {
+		return "", err
+	}
+
+	price, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return "", networkError(err)
+	}
+	if bumped := ethBumpedGasPrice(pending); price.Cmp(bumped) < 0 {
+		price = bumped
+	}
+	gas, err := gasLimit(client, signer.from, price)
+	if err != nil {
+		return "", err
+	}
+	tx, err := nonces.replace(ctx, client, signer.from, pending, func(nonce uint64) (*types.Transaction, error) {
+		return signer.sign(nonce, price, gas, to, value, data)
+	})
+	if err != nil {
+		return "", err
+	}
+	contract.forgetFeeTransaction(hash)
+	if contract.isSetPrepaidFeeData(&to, data) {
+		contract.watchFeeTransaction(tx.Hash().String())
+	}
+	return tx.Hash().String(), nil
+}
+
+// pendingTransaction the tracked transaction of the nonce manager, or the pending transaction of the chain sent by the account
+func (contract *ethRedPacketContract) pendingTransaction(ctx context.Context, client *ethclient.Client, nonces *EthNonceManager, signer *ethLegacySigner, hash common.Hash) (*types.Transaction, error) {
+	if tx, ok := nonces.pendingTransaction(signer.from, hash); ok {
+		return tx, nil
+	}
+	tx, isPending, err := client.TransactionByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, newInvalidParamsError("transaction %v not found", hash)
+		}
+		return nil, networkError(err)
+	}
+	if !isPending {
+		return nil, newInvalidParamsError("transaction %v is already confirmed", hash)
+	}
+	if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err != nil || from != signer.from {
+		return nil, newInvalidParamsError("transaction %v is not sent by the account", hash)
+	}
+	return tx, nil
+}
+
+// ethBumpedGasPrice the minimum gas price to replace the transaction, the legacy price must cover both the fee cap and tip of it
+func ethBumpedGasPrice(tx *types.Transaction) *big.Int {
+	price := tx.GasFeeCap()
+	if tip := tx.GasTipCap(); tip.Cmp(price) > 0 {
+		price = tip
+	}
+	bumped := big.NewInt(0).Mul(price, big.NewInt(100+ethReplacePriceBump))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+var _ EthTransactionReplacer = (*ethRedPacketContract)(nil)
@@ -0,0 +1,227 @@
+package redpacket
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/coming-chat/go-red-packet/redpacket/chainstub"
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/coming-chat/wallet-SDK/core/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+)
+
+// ethKeyAccount the eth account signs with the private key
+type ethKeyAccount struct {
+	base.Account
+	key []byte
+}
+
+func (a ethKeyAccount) Address() string {
+	key, _ := crypto.ToECDSA(a.key)
+	return crypto.PubkeyToAddress(key.PublicKey).String()
+}
+
+func (a ethKeyAccount) PrivateKey() ([]byte, error) {
+	return a.key, nil
+}
+
+func newEthTestSigner(t *testing.T) *ethLegacySigner {
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	return &ethLegacySigner{key: key, from: crypto.PubkeyToAddress(key.PublicKey), signer: types.NewEIP155Signer(big.NewInt(1))}
+}
+
+// handleSendRawTransaction decode the sent transactions, the handler returns the error to reject it
+func handleSendRawTransaction(server *chainstub.JSONRPCServer, handler func(tx *types.Transaction) error) {
+	server.Handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		var raw string
+		if err := json.Unmarshal(params[0], &raw); err != nil {
+			return nil, err
+		}
+		data, err := hexutil.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		tx := &types.Transaction{}
+		if err = tx.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		if err = handler(tx); err != nil {
+			return nil, err
+		}
+		return tx.Hash().String(), nil
+	})
+}
+
+func signWith(signer *ethLegacySigner, price int64) func(nonce uint64) (*types.Transaction, error) {
+	return func(nonce uint64) (*types.Transaction, error) {
+		return signer.sign(nonce, big.NewInt(price), ethCancelGasLimit, signer.from, big.NewInt(0), nil)
+	}
+}
+
+func TestEthNonceManager_ConcurrentSend(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_getTransactionCount", "0x5")
+	handleSendRawTransaction(server, func(tx *types.Transaction) error { return nil })
+
+	client, err := ethclient.Dial(server.URL)
+	require.Nil(t, err)
+	signer := newEthTestSigner(t)
+	manager := NewEthNonceManager()
+
+	var lock sync.Mutex
+	var nonces []int
+	var errs []error
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx, err := manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			nonces = append(nonces, int(tx.Nonce()))
+		}()
+	}
+	wg.Wait()
+	require.Empty(t, errs)
+	require.Len(t, nonces, 20)
+
+	sort.Ints(nonces)
+	for i, nonce := range nonces {
+		require.Equal(t, 5+i, nonce)
+	}
+	// synced once with the pending and the confirmed nonce
+	require.Len(t, server.Requests("eth_getTransactionCount"), 2)
+}
+
+func TestEthNonceManager_Resync(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	chainNonce := "0x5"
+	server.Handle("eth_getTransactionCount", func(params []json.RawMessage) (interface{}, error) {
+		return chainNonce, nil
+	})
+	var rejectErr error
+	handleSendRawTransaction(server, func(tx *types.Transaction) error {
+		err := rejectErr
+		rejectErr = nil
+		return err
+	})
+
+	client, err := ethclient.Dial(server.URL)
+	require.Nil(t, err)
+	signer := newEthTestSigner(t)
+	manager := NewEthNonceManager()
+
+	tx, err := manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+	require.Nil(t, err)
+	require.Equal(t, uint64(5), tx.Nonce())
+
+	// the nonces are taken by others, it's retried with the synced nonce
+	chainNonce = "0x8"
+	rejectErr = &chainstub.RPCError{Code: -32000, Message: "nonce too low"}
+	tx, err = manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+	require.Nil(t, err)
+	require.Equal(t, uint64(8), tx.Nonce())
+
+	// other errors are returned, and the nonce is synced at the next send
+	rejectErr = &chainstub.RPCError{Code: -32000, Message: "insufficient funds for gas * price + value"}
+	_, err = manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+	require.NotNil(t, err)
+	chainNonce = "0x9"
+	tx, err = manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+	require.Nil(t, err)
+	require.Equal(t, uint64(9), tx.Nonce())
+}
+
+func TestEthNonceManager_ReplaceUnderpriced(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_getTransactionCount", "0x5")
+	handleSendRawTransaction(server, func(tx *types.Transaction) error { return nil })
+
+	client, err := ethclient.Dial(server.URL)
+	require.Nil(t, err)
+	signer := newEthTestSigner(t)
+	manager := NewEthNonceManager()
+	pending, err := manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+	require.Nil(t, err)
+
+	handleSendRawTransaction(server, func(tx *types.Transaction) error {
+		return &chainstub.RPCError{Code: -32000, Message: "replacement transaction underpriced"}
+	})
+	_, err = manager.replace(context.Background(), client, signer.from, pending, signWith(signer, 105))
+	require.ErrorIs(t, err, ErrFeeMismatch)
+	require.False(t, IsRetryable(err))
+	// the pending one is still tracked
+	_, ok := manager.pendingTransaction(signer.from, pending.Hash())
+	require.True(t, ok)
+}
+
+func Test_ethBumpedGasPrice(t *testing.T) {
+	to := common.HexToAddress("0x1")
+	legacy := types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(101), To: &to})
+	require.Equal(t, "112", ethBumpedGasPrice(legacy).String())
+
+	dynamic := types.NewTx(&types.DynamicFeeTx{GasFeeCap: big.NewInt(200), GasTipCap: big.NewInt(10), To: &to})
+	require.Equal(t, "220", ethBumpedGasPrice(dynamic).String())
+}
+
+func TestEth_CancelTransaction(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+	server.HandleResult("eth_gasPrice", "0x32")
+	server.HandleResult("eth_getTransactionCount", "0x5")
+	var sent []*types.Transaction
+	handleSendRawTransaction(server, func(tx *types.Transaction) error {
+		sent = append(sent, tx)
+		return nil
+	})
+
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	account := ethKeyAccount{key: crypto.FromECDSA(key)}
+	manager := NewEthNonceManager()
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, &ContractConfig{EthNonceManager: manager})
+
+	client, err := contract.remoteClient()
+	require.Nil(t, err)
+	signer, err := newEthLegacySigner(context.Background(), client, account)
+	require.Nil(t, err)
+	stuck, err := manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+	require.Nil(t, err)
+
+	hash, err := contract.CancelTransaction(account, stuck.Hash().String())
+	require.Nil(t, err)
+	require.Len(t, sent, 2)
+	cancel := sent[1]
+	require.Equal(t, cancel.Hash().String(), hash)
+	require.Equal(t, stuck.Nonce(), cancel.Nonce())
+	// bumped 10% from the stuck price, the suggested 50 is lower
+	require.Equal(t, "110", cancel.GasPrice().String())
+	require.Equal(t, signer.from, *cancel.To())
+	require.Equal(t, uint64(ethCancelGasLimit), cancel.Gas())
+
+	// the stuck one is replaced, and the next send takes the next nonce
+	_, ok := manager.pendingTransaction(signer.from, stuck.Hash())
+	require.False(t, ok)
+	next, err := manager.send(context.Background(), client, signer.from, signWith(signer, 100))
+	require.Nil(t, err)
+	require.Equal(t, stuck.Nonce()+1, next.Nonce())
+}
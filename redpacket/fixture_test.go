@@ -0,0 +1,951 @@
+package redpacket
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/coming-chat/go-red-packet/redpacket/chainstub"
+	"github.com/coming-chat/go-sui/v2/types"
+	"github.com/coming-chat/wallet-SDK/core/aptos"
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/coming-chat/wallet-SDK/core/eth"
+	"github.com/coming-chat/wallet-SDK/core/sui"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/sha3"
+)
+
+// the golden fixtures in testdata are the rpc responses of the red packet transactions
+const (
+	fixtureEthContract  = "0x9f8a4b5e2d8c1e6f3a7b0c9d8e7f6a5b4c3d2e1f"
+	fixtureEthCreateTx  = "0x1c6eec00c38629a44d2155855433859bc315bcf628f2a25dafb972b9ac0d5902"
+	fixtureEthCreator   = "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+	fixtureAptosAddress = "0x2b0f5e1f9c8a7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e"
+	fixtureAptosCreator = "0x7c1e4a2f8b9d0e3c5a6f7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e"
+	fixtureAptosTx      = "0x5d0c7e0b1c8b3a9e6f2d4c1b0a9f8e7d6c5b4a39281706f5e4d3c2b1a0f9e8d7"
+	fixtureSuiPackage   = "0x5b2b8c1e0f3a4d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6"
+	fixtureSuiConfig    = "0x0b9d4f2e6a8c1d3e5f7091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e7"
+	fixtureSuiCreator   = "0x7c1e4a2f8b9d0e3c5a6f7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e"
+	fixtureSuiPacket    = "0x3e8f1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e8"
+	fixtureSuiDigest    = "8RBsoeyoRwajj86MZfZE6gMDJQVYGYcdSfx1zxqxNHbr"
+)
+
+func fixturePath(name string) string {
+	return filepath.Join("testdata", name)
+}
+
+func loadFixture(t *testing.T, name string, v interface{}) {
+	data, err := os.ReadFile(fixturePath(name))
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal(data, v))
+}
+
+func TestEth_FetchRedPacketCreationDetailWithFixture(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+	require.Nil(t, server.HandleFixture("eth_getTransactionByHash", fixturePath("eth/create_transaction.json")))
+	require.Nil(t, server.HandleFixture("eth_getTransactionReceipt", fixturePath("eth/create_receipt.json")))
+	require.Nil(t, server.HandleFixture("eth_getBlockByHash", fixturePath("eth/block_header.json")))
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	detail, err := contract.FetchRedPacketCreationDetail(fixtureEthCreateTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, fixtureEthCreator, detail.FromAddress)
+	require.Equal(t, int64(42), detail.PacketId)
+	require.Equal(t, "1000000000000000000", detail.Amount)
+	require.Equal(t, "1000000000000000000", detail.RedPacketAmount)
+	// gas 5 gwei * 120000 and the prepaid fee 0.002 in the value
+	require.Equal(t, "2600000000000000", detail.EstimateFees)
+	require.Equal(t, int16(18), detail.AmountDecimal)
+	require.Equal(t, int64(1672531200), detail.FinishTimestamp)
+	// the native coin red packet doesn't call the erc20 name and decimals
+	require.Empty(t, server.Requests("eth_call"))
+}
+
+func TestEth_FetchRedPacketCreationDetailNotFound(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+	server.HandleResult("eth_getTransactionByHash", nil)
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	_, err := contract.FetchRedPacketCreationDetail(fixtureEthCreateTx)
+	var dataErr *RedPacketDataError
+	require.True(t, errors.As(err, &dataErr))
+}
+
+func TestEth_FetchRedPacketCreationDetailReverted(t *testing.T) {
+	var receipt map[string]interface{}
+	loadFixture(t, "eth/create_receipt.json", &receipt)
+	receipt["status"] = "0x0"
+	receipt["logs"] = []interface{}{}
+
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+	server.HandleResult("eth_getTransactionReceipt", receipt)
+	require.Nil(t, server.HandleFixture("eth_getTransactionByHash", fixturePath("eth/create_transaction.json")))
+	require.Nil(t, server.HandleFixture("eth_getBlockByHash", fixturePath("eth/block_header.json")))
+	server.Handle("eth_call", func(params []json.RawMessage) (interface{}, error) {
+		// Error("ERC20: insufficient allowance")
+		return nil, &chainstub.RPCError{Code: 3, Message: "execution reverted: ERC20: insufficient allowance", Data: "0x08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"000000000000000000000000000000000000000000000000000000000000001d" +
+			"45524332303a20696e73756666696369656e7420616c6c6f77616e6365000000"}
+	})
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	detail, err := contract.FetchRedPacketCreationDetail(fixtureEthCreateTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusFailure, detail.Status)
+	require.Equal(t, "execution reverted: ERC20: insufficient allowance", detail.FailureMessage)
+	require.NotNil(t, detail.Abort)
+	require.ErrorIs(t, detail.Abort, ErrInsufficientBalance)
+
+	// the failed transaction is replayed at the parent block
+	requests := server.Requests("eth_call")
+	require.Len(t, requests, 1)
+	require.Equal(t, `"0xf423ff"`, string(requests[0].Params[1]))
+}
+
+func newAptosStubServer(t *testing.T) *chainstub.RESTServer {
+	server := chainstub.NewRESTServer()
+	require.Nil(t, server.HandleFixture(http.MethodGet, "/v1", fixturePath("aptos/ledger_info.json")))
+	require.Nil(t, server.HandleFixture(http.MethodGet, "/v1/transactions/by_hash/"+fixtureAptosTx, fixturePath("aptos/create_transaction.json")))
+	require.Nil(t, server.HandleFixture(http.MethodGet, "/v1/accounts/0x1/resource/0x1::coin::CoinInfo<0x1::aptos_coin::AptosCoin>", fixturePath("aptos/aptos_coin_info.json")))
+	return server
+}
+
+func TestAptos_SubmitSignedTransactionWithContext(t *testing.T) {
+	server := newAptosStubServer(t)
+	defer server.Close()
+	server.Handle(http.MethodPost, "/v1/transactions", func(body []byte) (int, interface{}) {
+		time.Sleep(100 * time.Millisecond)
+		return http.StatusAccepted, map[string]interface{}{"type": "pending_transaction", "hash": fixtureAptosTx}
+	})
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	signedTx := &SignedTransaction{Data: []byte{0x01}}
+	_, err := contract.remoteClient()
+	require.Nil(t, err)
+
+	// the submit isn't abandoned when ctx is done during it, the transaction may be broadcast
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	hash, err := contract.SubmitSignedTransactionWithContext(ctx, signedTx)
+	require.Nil(t, err)
+	require.Equal(t, fixtureAptosTx, hash)
+
+	// give up before the submit
+	_, err = contract.SubmitSignedTransactionWithContext(ctx, signedTx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Len(t, server.Requests("/v1/transactions"), 1)
+}
+
+func TestAptos_FetchRedPacketCreationDetailWithFixture(t *testing.T) {
+	server := newAptosStubServer(t)
+	defer server.Close()
+
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	detail, err := contract.FetchRedPacketCreationDetail(fixtureAptosTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, fixtureAptosCreator, detail.FromAddress)
+	require.Equal(t, fixtureAptosAddress, detail.ToAddress)
+	require.Equal(t, int64(41), detail.PacketId)
+	require.Equal(t, "102564000", detail.Amount)
+	require.Equal(t, "100000000", detail.RedPacketAmount)
+	require.Equal(t, "52100", detail.EstimateFees)
+	require.Equal(t, "Aptos Coin", detail.AmountName)
+	require.Equal(t, int16(8), detail.AmountDecimal)
+
+	// the invalid amount is rejected before reading the handlers
+	_, err = contract.EstimateFee(&RedPacketAction{
+		Method:       RPAMethodCreate,
+		CreateParams: &RedPacketCreateParams{TokenAddress: aptosCoinType, Count: 3, Amount: "1e8"},
+	})
+	require.ErrorIs(t, err, ErrInvalidParams)
+
+	// the created red packet isn't an open transaction
+	_, err = contract.FetchRedPacketOpenDetail(fixtureAptosTx)
+	var dataErr *RedPacketDataError
+	require.True(t, errors.As(err, &dataErr))
+
+	_, err = contract.FetchRedPacketCreationDetail("0x01")
+	require.True(t, errors.As(err, &dataErr))
+	require.Len(t, server.Requests("/v1/transactions/by_hash/0x01"), 1)
+}
+
+func TestAptos_BuildUnsignedTransactionSimulated(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 1
+	publicKey := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+	// the address of the account is the authentication key before rotation
+	authKey := sha3.Sum256(append([]byte(publicKey), 0x00))
+	sender := "0x" + hex.EncodeToString(authKey[:])
+
+	server := newAptosStubServer(t)
+	defer server.Close()
+	server.HandleResult(http.MethodGet, "/v1/accounts/"+fixtureAptosAddress+"/resource/"+fixtureAptosAddress+"::red_packet::GlobalConfig", http.StatusOK, map[string]interface{}{
+		"type": fixtureAptosAddress + "::red_packet::GlobalConfig",
+		"data": map[string]interface{}{"handlers": []interface{}{map[string]interface{}{
+			"coin_type": aptosCoinType, "handler_index": "0", "config": map[string]interface{}{"fee_point": 250, "base_prepaid": "4"},
+		}}},
+	})
+	server.HandleResult(http.MethodGet, "/v1/estimate_gas_price", http.StatusOK, map[string]interface{}{"gas_estimate": 100})
+	server.HandleResult(http.MethodGet, "/v1/accounts/"+sender, http.StatusOK, map[string]string{"sequence_number": "3", "authentication_key": sender})
+	server.HandleResult(http.MethodGet, "/v1/accounts/"+sender+"/transactions", http.StatusOK, []map[string]interface{}{{
+		"type": "user_transaction", "version": "100", "hash": "0x01", "sender": sender, "sequence_number": "2", "success": true,
+		"signature": map[string]string{"type": "ed25519_signature", "public_key": "0x" + hex.EncodeToString(publicKey), "signature": "0x" + strings.Repeat("00", 64)},
+	}})
+	server.HandleResult(http.MethodPost, "/v1/transactions/simulate", http.StatusOK, []map[string]interface{}{{
+		"type": "user_transaction", "version": "0", "hash": "0x02", "success": true, "vm_status": "Executed successfully", "gas_used": "1000", "gas_unit_price": "100",
+	}})
+
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	create, err := NewRedPacketActionCreate(aptosCoinType, 3, "100000000")
+	require.Nil(t, err)
+	unsigned, err := contract.BuildUnsignedTransaction(sender, create)
+	require.Nil(t, err)
+	// the simulated gas used 1000 with 50% margin
+	require.Equal(t, uint64(1500), unsigned.GasLimit)
+	require.Equal(t, "100000", unsigned.EstimateGasFee)
+	require.Equal(t, "limit=1&start=2", server.Requests("/v1/accounts/" + sender + "/transactions")[0].Query)
+
+	server.HandleResult(http.MethodPost, "/v1/transactions/simulate", http.StatusOK, []map[string]interface{}{{
+		"type": "user_transaction", "version": "0", "hash": "0x02", "success": false, "gas_used": "10", "gas_unit_price": "100",
+		"vm_status": "Move abort in " + fixtureAptosAddress + "::red_packet: EREDPACKET_NOT_FOUND(0x6): ",
+	}})
+	_, err = contract.BuildUnsignedTransaction(sender, create)
+	require.ErrorIs(t, err, ErrContractAbort)
+
+	// the public key of the new account is unknown, it's not simulated
+	server.HandleResult(http.MethodGet, "/v1/accounts/"+sender, http.StatusOK, map[string]string{"sequence_number": "0", "authentication_key": sender})
+	unsigned, err = contract.BuildUnsignedTransaction(sender, create)
+	require.Nil(t, err)
+	require.Equal(t, uint64(aptosMaxGasAmount), unsigned.GasLimit)
+	require.Equal(t, "2000000", unsigned.EstimateGasFee)
+}
+
+func Test_toBaseTransaction(t *testing.T) {
+	var transaction aptostypes.Transaction
+	loadFixture(t, "aptos/create_transaction.json", &transaction)
+	detail, err := toBaseTransaction(&transaction)
+	require.Nil(t, err)
+	require.Equal(t, fixtureAptosTx, detail.HashString)
+	require.Equal(t, "3", detail.Amount)
+	require.Equal(t, int64(1672531200), detail.FinishTimestamp)
+
+	transaction.Success = false
+	transaction.VmStatus = "Move abort"
+	detail, err = toBaseTransaction(&transaction)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusFailure, detail.Status)
+	require.Equal(t, "Move abort", detail.FailureMessage)
+
+	transaction.Type = aptostypes.TypePendingTransaction
+	_, err = toBaseTransaction(&transaction)
+	require.NotNil(t, err)
+}
+
+func TestSui_FetchRedPacketCreationDetailWithFixture(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	require.Nil(t, server.HandleFixture("sui_getTransactionBlock", fixturePath("sui/create_transaction_block.json")))
+	require.Nil(t, server.HandleFixture("suix_getCoinMetadata", fixturePath("sui/sui_coin_metadata.json")))
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	detail, err := contract.FetchRedPacketCreationDetail(fixtureSuiDigest)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, fixtureSuiCreator, detail.FromAddress)
+	require.Equal(t, fixtureSuiPackage, detail.ToAddress)
+	require.Equal(t, fixtureSuiPacket, detail.PacketObjectId)
+	require.Equal(t, "102564000", detail.Amount)
+	require.Equal(t, "100000000", detail.RedPacketAmount)
+	// computation 1000000 + storage 4000000 - rebate 978120
+	require.Equal(t, "4021880", detail.EstimateFees)
+	require.Equal(t, int16(9), detail.AmountDecimal)
+	require.Equal(t, int64(1672531200), detail.FinishTimestamp)
+
+	requests := server.Requests("sui_getTransactionBlock")
+	require.Len(t, requests, 1)
+	require.Equal(t, `"`+fixtureSuiDigest+`"`, string(requests[0].Params[0]))
+}
+
+func TestSui_FetchBatchResultWithFixture(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	require.Nil(t, server.HandleFixture("sui_getTransactionBlock", fixturePath("sui/create_transaction_block.json")))
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	batchContract, ok := contract.(RedPacketBatchContract)
+	require.True(t, ok)
+	result, err := batchContract.FetchBatchResult(fixtureSuiDigest)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, result.Status)
+	require.Nil(t, result.Abort)
+	require.Equal(t, []*RedPacketEvent{{
+		Method:         RPAMethodCreate,
+		PacketObjectId: fixtureSuiPacket,
+		RemainCount:    3,
+		RemainBalance:  "100000000",
+	}}, result.Events)
+}
+
+func Test_getAmountBySuiEvents(t *testing.T) {
+	var resp types.SuiTransactionBlockResponse
+	loadFixture(t, "sui/create_transaction_block.json", &resp)
+
+	amount, err := getAmountBySuiEvents(resp.Events)
+	require.Nil(t, err)
+	require.Equal(t, uint64(100000000), amount)
+
+	coinType, detail, err := toSuiBaseTransaction(fixtureSuiDigest, &resp)
+	require.Nil(t, err)
+	require.Equal(t, suiCoinAddress, coinType)
+	require.Equal(t, "102564000", detail.Amount)
+
+	_, err = getAmountBySuiEvents(nil)
+	require.NotNil(t, err)
+}
+
+const (
+	fixtureEthOpenTx  = "0x6443cbb0697121364cf966d53641fd68513fb4f1769ba5e0841a30ba77f68cc0"
+	fixtureEthCloseTx = "0x5b4b2f066203f5409ce9fe390979898b3b955121520074190cb8dc2ef16766b1"
+	fixtureEthToken   = "0x7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b"
+
+	fixtureAptosOpenTx    = "0xfdd45039b3702c77bb3254ccb20a863b7ab518aff89a718dfaad8c172d164c86"
+	fixtureSuiOpenDigest  = "7J8EAGkH6mSyJry5GW1z3362oQc4cQKaWFMAthLEu8cA"
+	fixtureAptosCloseTx   = "0xe4d9d8aa87b7ea259f7d984d393cf30c1425fb34530188a67f4b4105a066f4c9"
+	fixtureSuiCloseDigest = "DNA2uXpG5uHphKg8qpv3dTyGgFjF9kQ97mw2kCde9p7n"
+	fixtureLuckyAccount1  = "0x46d86146774497af0aa4959c0777932a52de8b79f6babec58d58a4a0d8d5d68e"
+	fixtureLuckyAccount2  = "0xd6059ff0e4be793880322bd7b16d9b9483ef939d7f17d8a01dadc50b203fb308"
+)
+
+// ethCallHandler answer the eth_call by the called contract and method, the missing one returns the pruned state error
+func ethCallHandler(t *testing.T, results map[string]map[string][]interface{}) chainstub.JSONRPCHandler {
+	return func(params []json.RawMessage) (interface{}, error) {
+		var msg struct {
+			To    common.Address `json:"to"`
+			Data  hexutil.Bytes  `json:"data"`
+			Input hexutil.Bytes  `json:"input"`
+		}
+		require.Nil(t, json.Unmarshal(params[0], &msg))
+		data := msg.Data
+		if len(data) == 0 {
+			data = msg.Input
+		}
+		contractAbi := erc20ABI
+		if msg.To == common.HexToAddress(fixtureEthContract) {
+			contractAbi = redPacketABI
+		}
+		method, err := contractAbi.MethodById(data)
+		require.Nil(t, err)
+		values, ok := results[msg.To.Hex()][method.Name]
+		if !ok {
+			return nil, &chainstub.RPCError{Code: -32000, Message: "missing trie node"}
+		}
+		output, err := method.Outputs.Pack(values...)
+		require.Nil(t, err)
+		return hexutil.Encode(output), nil
+	}
+}
+
+// newEthCloseStubServer serve the close of packet 42 at index 2, and the open of it at index 1 in the same block
+func newEthCloseStubServer(t *testing.T, receipt map[string]interface{}, calls map[string]map[string][]interface{}) *chainstub.JSONRPCServer {
+	var openReceipt map[string]interface{}
+	loadFixture(t, "eth/open_receipt.json", &openReceipt)
+
+	server := chainstub.NewJSONRPCServer()
+	server.HandleResult("eth_chainId", "0x1")
+	server.HandleResult("eth_getTransactionReceipt", receipt)
+	server.HandleResult("eth_getLogs", openReceipt["logs"])
+	server.Handle("eth_call", ethCallHandler(t, calls))
+	require.Nil(t, server.HandleFixture("eth_getTransactionByHash", fixturePath("eth/close_transaction.json")))
+	require.Nil(t, server.HandleFixture("eth_getBlockByHash", fixturePath("eth/block_header.json")))
+	return server
+}
+
+func TestEth_FetchRedPacketCloseDetailWithFixture(t *testing.T) {
+	var receipt map[string]interface{}
+	loadFixture(t, "eth/close_receipt.json", &receipt)
+	// the parent block state is before the open in the same block
+	server := newEthCloseStubServer(t, receipt, map[string]map[string][]interface{}{
+		common.HexToAddress(fixtureEthContract).Hex(): {
+			"red_envelop_infos": {common.Address{}, big.NewInt(3), big.NewInt(1000000000000000000)},
+		},
+	})
+	defer server.Close()
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureEthCloseTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, ChainTypeEth, detail.ChainName)
+	require.Equal(t, int64(42), detail.PacketId)
+	require.Equal(t, fixtureEthCreator, detail.Creator)
+	// the native coin refund is the remain balance after the open before it
+	require.Equal(t, "500000000000000000", detail.RefundAmount)
+	require.Equal(t, detail.RefundAmount, detail.Amount)
+	require.Equal(t, int16(18), detail.AmountDecimal)
+	require.Nil(t, detail.Abort)
+
+	requests := server.Requests("eth_call")
+	require.Len(t, requests, 1)
+	require.Equal(t, `"0xf423ff"`, string(requests[0].Params[1]))
+}
+
+func TestEth_FetchRedPacketCloseDetailPrunedState(t *testing.T) {
+	var receipt map[string]interface{}
+	loadFixture(t, "eth/close_receipt.json", &receipt)
+	server := newEthCloseStubServer(t, receipt, nil)
+	defer server.Close()
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+
+	// the refund of the native coin packet is still known by the open in the same block
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureEthCloseTx)
+	require.Nil(t, err)
+	require.Equal(t, "500000000000000000", detail.RefundAmount)
+	require.Equal(t, int16(18), detail.AmountDecimal)
+
+	// nothing tells the refund without the state
+	server.HandleResult("eth_getLogs", []interface{}{})
+	detail, err = contract.FetchRedPacketCloseDetail(fixtureEthCloseTx)
+	var dataErr *RedPacketDataError
+	require.True(t, errors.As(err, &dataErr))
+	require.Equal(t, "0", detail.RefundAmount)
+}
+
+func TestEth_FetchRedPacketCloseDetailErc20(t *testing.T) {
+	var receipt map[string]interface{}
+	loadFixture(t, "eth/close_receipt.json", &receipt)
+	// Transfer(contract, creator, 0.5 token) of the refund
+	receipt["logs"] = []interface{}{map[string]interface{}{
+		"address": fixtureEthToken,
+		"topics": []string{
+			erc20ABI.Events["Transfer"].ID.Hex(),
+			common.BytesToHash(common.HexToAddress(fixtureEthContract).Bytes()).Hex(),
+			common.BytesToHash(common.HexToAddress(fixtureEthCreator).Bytes()).Hex(),
+		},
+		"data":             hexutil.Encode(common.LeftPadBytes(big.NewInt(500000000000000000).Bytes(), 32)),
+		"blockNumber":      "0xf42400",
+		"transactionHash":  fixtureEthCloseTx,
+		"transactionIndex": "0x2",
+		"blockHash":        "0x854a426738f72584e97af0d17e0f6b85aa663559ac7b6f674d1eae7fae134dba",
+		"logIndex":         "0x2",
+		"removed":          false,
+	}}
+	server := newEthCloseStubServer(t, receipt, map[string]map[string][]interface{}{
+		common.HexToAddress(fixtureEthToken).Hex(): {
+			"name":     {"Tether USD"},
+			"decimals": {uint8(6)},
+		},
+	})
+	defer server.Close()
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureEthCloseTx)
+	require.Nil(t, err)
+	require.Equal(t, "500000000000000000", detail.RefundAmount)
+	require.Equal(t, "Tether USD", detail.AmountName)
+	require.Equal(t, int16(6), detail.AmountDecimal)
+	// the refund transfer is exact, the state and the logs of the block are not read
+	require.Empty(t, server.Requests("eth_getLogs"))
+}
+
+func TestEth_FetchRedPacketCloseDetailReverted(t *testing.T) {
+	var receipt map[string]interface{}
+	loadFixture(t, "eth/close_receipt.json", &receipt)
+	receipt["status"] = "0x0"
+	server := newEthCloseStubServer(t, receipt, nil)
+	defer server.Close()
+	server.Handle("eth_call", func(params []json.RawMessage) (interface{}, error) {
+		// Error("not creator")
+		return nil, &chainstub.RPCError{Code: 3, Message: "execution reverted: not creator", Data: "0x08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"000000000000000000000000000000000000000000000000000000000000000b" +
+			"6e6f742063726561746f72000000000000000000000000000000000000000000"}
+	})
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureEthCloseTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusFailure, detail.Status)
+	require.Equal(t, "execution reverted: not creator", detail.FailureMessage)
+	require.NotNil(t, detail.Abort)
+	require.Equal(t, "0", detail.RefundAmount)
+	require.Empty(t, server.Requests("eth_getLogs"))
+}
+
+func TestEth_EstimateFeeFallback(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	var results map[string]interface{}
+	server.Handle("eth_call", func(params []json.RawMessage) (interface{}, error) {
+		var msg struct {
+			Data  hexutil.Bytes `json:"data"`
+			Input hexutil.Bytes `json:"input"`
+		}
+		require.Nil(t, json.Unmarshal(params[0], &msg))
+		data := append(msg.Data, msg.Input...)
+		method, err := redPacketABI.MethodById(data)
+		require.Nil(t, err)
+		if err, ok := results[method.Name].(error); ok {
+			return nil, err
+		}
+		return results[method.Name], nil
+	})
+	reverted := &chainstub.RPCError{Code: 3, Message: "execution reverted"}
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	create, err := NewRedPacketActionCreate("", 3, "1000")
+	require.Nil(t, err)
+
+	// calc_prepaid_fee reverts, base_prepaid_fee 0.001 * 4
+	results = map[string]interface{}{"calc_prepaid_fee": reverted, "base_prepaid_fee": hexutil.Encode(common.LeftPadBytes(big.NewInt(1e15).Bytes(), 32))}
+	fee, err := contract.EstimateFee(create)
+	require.Nil(t, err)
+	require.Equal(t, "4000000000000000", fee)
+
+	// the old contract doesn't have the methods, the default base fee 0.025 * 4
+	results = map[string]interface{}{"calc_prepaid_fee": "0x", "base_prepaid_fee": reverted}
+	fee, err = contract.EstimateFee(create)
+	require.Nil(t, err)
+	require.Equal(t, "100000000000000000", fee)
+
+	// the revert with a reason is the failure of calc_prepaid_fee, not the missing method
+	results = map[string]interface{}{"calc_prepaid_fee": &chainstub.RPCError{Code: 3, Message: "execution reverted: count exceeds max_count", Data: "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000017" +
+		"636f756e742065786365656473206d61785f636f756e74000000000000000000"}}
+	_, err = contract.EstimateFee(create)
+	var abort *ContractAbortError
+	require.True(t, errors.As(err, &abort))
+	require.Equal(t, "count exceeds max_count", abort.Reason)
+
+	// so is the custom error
+	results = map[string]interface{}{"calc_prepaid_fee": &chainstub.RPCError{Code: 3, Message: "execution reverted", Data: "0xab35696f"}}
+	_, err = contract.EstimateFee(create)
+	require.True(t, errors.As(err, &abort))
+	require.Equal(t, "custom error 0xab35696f", abort.Reason)
+
+	// the network error is returned instead of the default fee
+	server.Close()
+	_, err = contract.EstimateFee(create)
+	require.True(t, IsRetryable(err))
+}
+
+func TestEth_FeeCacheWithPendingSetPrepaidFee(t *testing.T) {
+	contract := newEthRedPacketContract(eth.NewChainWithRpc("http://127.0.0.1:0"), fixtureEthContract, &ContractConfig{EthFeeCacheDuration: time.Minute})
+	contract.setCachedFee(3, big.NewInt(100))
+	fee, ok := contract.cachedFee(3)
+	require.True(t, ok)
+	require.Equal(t, "100", fee.String())
+
+	// the fee read before the set_prepaid_fee transaction is executed is not cached
+	contract.watchFeeTransaction(fixtureEthCreateTx)
+	contract.setCachedFee(3, big.NewInt(100))
+	_, ok = contract.cachedFee(3)
+	require.False(t, ok)
+
+	contract.forgetFeeTransaction(fixtureEthCreateTx)
+	contract.setCachedFee(3, big.NewInt(200))
+	fee, ok = contract.cachedFee(3)
+	require.True(t, ok)
+	require.Equal(t, "200", fee.String())
+}
+
+func TestEth_FeeCacheWithUnwaitedSetPrepaidFee(t *testing.T) {
+	contract := newEthRedPacketContract(eth.NewChainWithRpc("http://127.0.0.1:0"), fixtureEthContract, &ContractConfig{EthFeeCacheDuration: time.Minute})
+	// nobody waits for the set_prepaid_fee transaction
+	contract.watchFeeTransaction(fixtureEthCreateTx)
+	contract.setCachedFee(3, big.NewInt(100))
+	_, ok := contract.cachedFee(3)
+	require.False(t, ok)
+
+	// the fee is cached again after the watch is expired
+	contract.feeTransactions[common.HexToHash(fixtureEthCreateTx)] = time.Now().Add(-time.Second)
+	contract.setCachedFee(3, big.NewInt(200))
+	fee, ok := contract.cachedFee(3)
+	require.True(t, ok)
+	require.Equal(t, "200", fee.String())
+	require.Empty(t, contract.feeTransactions)
+}
+
+func TestEth_PrepareCreate(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	allowance := map[string]map[string][]interface{}{common.HexToAddress(fixtureEthToken).Hex(): {"allowance": {big.NewInt(50)}}}
+	server.Handle("eth_call", ethCallHandler(t, allowance))
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	create, err := NewRedPacketActionCreate(fixtureEthToken, 3, "100")
+	require.Nil(t, err)
+
+	// the non-zero allowance is approved to 0 first
+	approve, err := contract.PrepareCreate(fixtureEthCreator, create)
+	require.Nil(t, err)
+	require.Equal(t, "0", approve.ApproveParams.Amount)
+
+	allowance[common.HexToAddress(fixtureEthToken).Hex()]["allowance"] = []interface{}{big.NewInt(0)}
+	approve, err = contract.PrepareCreate(fixtureEthCreator, create)
+	require.Nil(t, err)
+	require.Equal(t, "100", approve.ApproveParams.Amount)
+
+	allowance[common.HexToAddress(fixtureEthToken).Hex()]["allowance"] = []interface{}{big.NewInt(100)}
+	approve, err = contract.PrepareCreate(fixtureEthCreator, create)
+	require.Nil(t, err)
+	require.Nil(t, approve)
+
+	create.CreateParams.TokenAddress = "0x1234"
+	_, err = contract.PrepareCreate(fixtureEthCreator, create)
+	require.ErrorIs(t, err, ErrInvalidParams)
+}
+
+func TestEth_FetchRedPacketState(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	calls := map[string]map[string][]interface{}{common.HexToAddress(fixtureEthContract).Hex(): {
+		"red_envelop_infos": {common.HexToAddress(fixtureEthToken), big.NewInt(2), big.NewInt(3000)},
+		"is_valid":          {true},
+	}}
+	server.Handle("eth_call", ethCallHandler(t, calls))
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	state, err := contract.FetchRedPacketState("", 42, "")
+	require.Nil(t, err)
+	require.Equal(t, &RedPacketState{
+		TokenAddress:  common.HexToAddress(fixtureEthToken).String(),
+		RemainCount:   2,
+		RemainBalance: "3000",
+		Valid:         true,
+	}, state)
+
+	// the red packet is closed with the remain still in the infos
+	calls[common.HexToAddress(fixtureEthContract).Hex()]["is_valid"] = []interface{}{false}
+	_, err = contract.FetchRedPacketState("", 42, "")
+	require.ErrorIs(t, err, ErrPacketClosed)
+
+	// nothing remains after the red packet is exhausted or refunded
+	calls[common.HexToAddress(fixtureEthContract).Hex()] = map[string][]interface{}{
+		"red_envelop_infos": {common.Address{}, big.NewInt(0), big.NewInt(0)},
+		"is_valid":          {false},
+	}
+	state, err = contract.FetchRedPacketState("", 42, "")
+	require.Nil(t, err)
+	require.False(t, state.Valid)
+	require.Equal(t, "0", state.RemainBalance)
+}
+
+func TestAptos_FetchRedPacketState(t *testing.T) {
+	server := newAptosStubServer(t)
+	defer server.Close()
+	require.Nil(t, server.HandleFixture(http.MethodGet, "/v1/accounts/"+fixtureAptosAddress+"/resource/"+fixtureAptosAddress+"::red_packet::GlobalConfig", fixturePath("aptos/global_config.json")))
+	itemPath := "/v1/tables/0x9e4b1c7a2d5f8e0b3c6a9d2e5f8b1c4a7d0e3f6b9c2a5d8e1f4b7c0a3d6e9f2b/item"
+	server.HandleResult(http.MethodPost, itemPath, http.StatusOK, map[string]string{"remain_coin": "60000000", "remain_count": "2"})
+
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	state, err := contract.FetchRedPacketState(aptosCoinType, 41, "")
+	require.Nil(t, err)
+	require.Equal(t, &RedPacketState{
+		TokenAddress:  aptosCoinType,
+		RemainCount:   2,
+		RemainBalance: "60000000",
+		Valid:         true,
+	}, state)
+	var request aptosclient.TableItemRequest
+	require.Nil(t, json.Unmarshal(server.Requests(itemPath)[0].Body, &request))
+	require.Equal(t, "41", request.Key)
+
+	// the closed red packet is removed from the table
+	server.HandleResult(http.MethodPost, itemPath, http.StatusNotFound, map[string]string{"message": "Table Item not found", "error_code": "table_item_not_found"})
+	_, err = contract.FetchRedPacketState(aptosCoinType, 41, "")
+	require.ErrorIs(t, err, ErrPacketNotFound)
+}
+
+func TestAptos_TokenCache(t *testing.T) {
+	server := newAptosStubServer(t)
+	defer server.Close()
+	configPath := "/v1/accounts/" + fixtureAptosAddress + "/resource/" + fixtureAptosAddress + "::red_packet::GlobalConfig"
+	require.Nil(t, server.HandleFixture(http.MethodGet, configPath, fixturePath("aptos/global_config.json")))
+	create, err := NewRedPacketActionCreate(aptosCoinType, 3, "100000000")
+	require.Nil(t, err)
+
+	// the handlers are cached for 1 minute by default
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	for i := 0; i < 2; i++ {
+		_, err = contract.EstimateFee(create)
+		require.Nil(t, err)
+	}
+	require.Len(t, server.Requests(configPath), 1)
+
+	contract.InvalidateTokenCache()
+	_, err = contract.EstimateFee(create)
+	require.Nil(t, err)
+	require.Len(t, server.Requests(configPath), 2)
+
+	contract.tokenCacheExpiredAt = time.Now().Add(-time.Second)
+	_, err = contract.EstimateFee(create)
+	require.Nil(t, err)
+	require.Len(t, server.Requests(configPath), 3)
+
+	// the unknown coin refresh the cached handlers once
+	unknown, err := NewRedPacketActionCreate("0x1::unknown::Coin", 3, "100000000")
+	require.Nil(t, err)
+	_, err = contract.EstimateFee(unknown)
+	require.ErrorIs(t, err, ErrUnsupportedToken)
+	require.Len(t, server.Requests(configPath), 4)
+
+	// negative duration means no cache
+	contract = newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, &ContractConfig{AptosTokenCacheDuration: -1})
+	for i := 0; i < 2; i++ {
+		_, err = contract.EstimateFee(create)
+		require.Nil(t, err)
+	}
+	require.Len(t, server.Requests(configPath), 6)
+}
+
+func TestSui_FetchRedPacketState(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	require.Nil(t, server.HandleFixture("sui_getObject", fixturePath("sui/packet_object.json")))
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	// the coin type is read from the object type
+	state, err := contract.FetchRedPacketState("", 0, fixtureSuiPacket)
+	require.Nil(t, err)
+	require.Equal(t, &RedPacketState{
+		TokenAddress:  "0x2::sui::SUI",
+		Creator:       fixtureSuiCreator,
+		RemainCount:   2,
+		RemainBalance: "60000000",
+		Valid:         true,
+	}, state)
+
+	// the object type without the coin type keeps the token address of the caller
+	var object map[string]interface{}
+	loadFixture(t, "sui/packet_object.json", &object)
+	object["data"].(map[string]interface{})["type"] = fixtureSuiPackage + "::red_packet::RedPacketInfo"
+	server.HandleResult("sui_getObject", object)
+	state, err = contract.FetchRedPacketState(suiCoinAddress, 0, fixtureSuiPacket)
+	require.Nil(t, err)
+	require.Equal(t, suiCoinAddress, state.TokenAddress)
+
+	// the closed red packet object is deleted
+	server.HandleResult("sui_getObject", json.RawMessage(`{"error":{"code":"deleted","object_id":"`+fixtureSuiPacket+`","version":"26","digest":"FnqbqF7YJekTNEMkZJMcujSouSfd4CzTacotg2LmSqeV"}}`))
+	_, err = contract.FetchRedPacketState(suiCoinAddress, 0, fixtureSuiPacket)
+	require.ErrorIs(t, err, ErrPacketNotFound)
+}
+
+func TestEth_FetchRedPacketOpenDetailWithFixture(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("eth_chainId", "0x1")
+	require.Nil(t, server.HandleFixture("eth_getTransactionByHash", fixturePath("eth/open_transaction.json")))
+	require.Nil(t, server.HandleFixture("eth_getTransactionReceipt", fixturePath("eth/open_receipt.json")))
+	require.Nil(t, server.HandleFixture("eth_getBlockByHash", fixturePath("eth/block_header.json")))
+	server.Handle("eth_call", ethCallHandler(t, map[string]map[string][]interface{}{
+		common.HexToAddress(fixtureEthContract).Hex(): {
+			"red_envelop_infos": {common.Address{}, big.NewInt(1), big.NewInt(500000000000000000)},
+		},
+	}))
+
+	contract := newEthRedPacketContract(eth.NewChainWithRpc(server.URL), fixtureEthContract, nil)
+	detail, err := contract.FetchRedPacketOpenDetail(fixtureEthOpenTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, ChainTypeEth, detail.ChainName)
+	require.Equal(t, int64(42), detail.PacketId)
+	require.Equal(t, []string{
+		common.HexToAddress("0x1111111111111111111111111111111111111111").String(),
+		common.HexToAddress("0x2222222222222222222222222222222222222222").String(),
+	}, detail.Addresses)
+	require.Equal(t, []string{"300000000000000000", "200000000000000000"}, detail.Amounts)
+	require.Equal(t, "500000000000000000", detail.Amount)
+	// the remain is the UpdateRedEnvelop of the open
+	require.Equal(t, int64(1), detail.RemainCount)
+	require.Equal(t, "500000000000000000", detail.RemainBalance)
+	require.Equal(t, int16(18), detail.AmountDecimal)
+	require.Nil(t, detail.Abort)
+
+	// the open transaction isn't a close transaction
+	_, err = contract.FetchRedPacketCloseDetail(fixtureEthOpenTx)
+	var dataErr *RedPacketDataError
+	require.True(t, errors.As(err, &dataErr))
+}
+
+func TestAptos_FetchRedPacketOpenDetailWithFixture(t *testing.T) {
+	server := newAptosStubServer(t)
+	defer server.Close()
+	require.Nil(t, server.HandleFixture(http.MethodGet, "/v1/transactions/by_hash/"+fixtureAptosOpenTx, fixturePath("aptos/open_transaction.json")))
+
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	detail, err := contract.FetchRedPacketOpenDetail(fixtureAptosOpenTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, ChainTypeAptos, detail.ChainName)
+	require.Equal(t, fixtureAptosAddress, detail.FromAddress)
+	require.Equal(t, int64(41), detail.PacketId)
+	require.Equal(t, []string{fixtureLuckyAccount1, fixtureLuckyAccount2}, detail.Addresses)
+	require.Equal(t, []string{"30000000", "10000000"}, detail.Amounts)
+	require.Equal(t, "40000000", detail.Amount)
+	require.Equal(t, int64(1), detail.RemainCount)
+	require.Equal(t, "60000000", detail.RemainBalance)
+	require.Equal(t, "3800", detail.EstimateFees)
+	require.Equal(t, int16(8), detail.AmountDecimal)
+	require.Nil(t, detail.Abort)
+
+	// the open transaction isn't a close transaction
+	_, err = contract.FetchRedPacketCloseDetail(fixtureAptosOpenTx)
+	var dataErr *RedPacketDataError
+	require.True(t, errors.As(err, &dataErr))
+}
+
+func TestSui_FetchRedPacketOpenDetailWithFixture(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	require.Nil(t, server.HandleFixture("sui_getTransactionBlock", fixturePath("sui/open_transaction_block.json")))
+	require.Nil(t, server.HandleFixture("suix_getCoinMetadata", fixturePath("sui/sui_coin_metadata.json")))
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	detail, err := contract.FetchRedPacketOpenDetail(fixtureSuiOpenDigest)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, ChainTypeSui, detail.ChainName)
+	require.Equal(t, fixtureSuiPackage, detail.ToAddress)
+	require.Equal(t, fixtureSuiPacket, detail.PacketObjectId)
+	require.Equal(t, []string{fixtureLuckyAccount1, fixtureLuckyAccount2}, detail.Addresses)
+	require.Equal(t, []string{"30000000", "10000000"}, detail.Amounts)
+	require.Equal(t, "40000000", detail.Amount)
+	require.Equal(t, int64(1), detail.RemainCount)
+	require.Equal(t, "60000000", detail.RemainBalance)
+	// computation 1000000 + storage 2964000 - rebate 1956240
+	require.Equal(t, "2007760", detail.EstimateFees)
+	require.Equal(t, int16(9), detail.AmountDecimal)
+	require.Nil(t, detail.Abort)
+
+	// the open transaction isn't a create transaction
+	_, err = contract.FetchRedPacketCreationDetail(fixtureSuiOpenDigest)
+	var dataErr *RedPacketDataError
+	require.True(t, errors.As(err, &dataErr))
+}
+
+func TestAptos_FetchRedPacketCloseDetailWithFixture(t *testing.T) {
+	server := newAptosStubServer(t)
+	defer server.Close()
+	require.Nil(t, server.HandleFixture(http.MethodGet, "/v1/transactions/by_hash/"+fixtureAptosCloseTx, fixturePath("aptos/close_transaction.json")))
+
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureAptosCloseTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, ChainTypeAptos, detail.ChainName)
+	require.Equal(t, int64(41), detail.PacketId)
+	// the refund of the native coin is deposited to the creator
+	require.Equal(t, fixtureAptosCreator, detail.Creator)
+	require.Equal(t, "60000000", detail.RefundAmount)
+	require.Equal(t, detail.RefundAmount, detail.Amount)
+	require.Equal(t, "2700", detail.EstimateFees)
+	require.Equal(t, "Aptos Coin", detail.AmountName)
+	require.Nil(t, detail.Abort)
+}
+
+func TestAptos_FetchRedPacketCloseDetailReverted(t *testing.T) {
+	var transaction map[string]interface{}
+	loadFixture(t, "aptos/close_transaction.json", &transaction)
+	transaction["success"] = false
+	transaction["vm_status"] = "Move abort in " + fixtureAptosAddress + "::red_packet: EREDPACKET_PERMISSION_DENIED(0x50003): "
+	transaction["events"] = []interface{}{}
+	server := newAptosStubServer(t)
+	defer server.Close()
+	server.HandleResult(http.MethodGet, "/v1/transactions/by_hash/"+fixtureAptosCloseTx, http.StatusOK, transaction)
+
+	contract := newAptosRedPacketContract(aptos.NewChainWithRestUrl(server.URL), fixtureAptosAddress, nil)
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureAptosCloseTx)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusFailure, detail.Status)
+	require.Equal(t, int64(41), detail.PacketId)
+	require.Empty(t, detail.Creator)
+	require.Equal(t, "0", detail.RefundAmount)
+	require.Equal(t, "0", detail.Amount)
+	require.NotNil(t, detail.Abort)
+	require.Equal(t, "EREDPACKET_PERMISSION_DENIED", detail.Abort.Name)
+}
+
+func TestSui_FetchRedPacketCloseDetailWithFixture(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	require.Nil(t, server.HandleFixture("sui_getTransactionBlock", fixturePath("sui/close_transaction_block.json")))
+	require.Nil(t, server.HandleFixture("suix_getCoinMetadata", fixturePath("sui/sui_coin_metadata.json")))
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureSuiCloseDigest)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusSuccess, detail.Status)
+	require.Equal(t, ChainTypeSui, detail.ChainName)
+	require.Equal(t, fixtureSuiPacket, detail.PacketObjectId)
+	// the refund of the native coin is the balance change of the creator, not the gas of the sender
+	require.Equal(t, fixtureSuiCreator, detail.Creator)
+	require.Equal(t, "60000000", detail.RefundAmount)
+	require.Equal(t, detail.RefundAmount, detail.Amount)
+	// computation 1000000 + storage 988000 - rebate 1956240
+	require.Equal(t, "31760", detail.EstimateFees)
+	require.Nil(t, detail.Abort)
+}
+
+func TestSui_FetchRedPacketCloseDetailReverted(t *testing.T) {
+	var resp map[string]interface{}
+	loadFixture(t, "sui/close_transaction_block.json", &resp)
+	resp["effects"].(map[string]interface{})["status"] = map[string]interface{}{
+		"status": "failure",
+		"error": `MoveAbort(MoveLocation { module: ModuleId { address: ` + strings.TrimPrefix(fixtureSuiPackage, "0x") +
+			`, name: Identifier("red_packet") }, function: 3, instruction: 12, function_name: Some("close") }, 3) in command 0`,
+	}
+	resp["events"] = []interface{}{}
+	resp["balanceChanges"] = resp["balanceChanges"].([]interface{})[:1]
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("sui_getTransactionBlock", resp)
+	require.Nil(t, server.HandleFixture("suix_getCoinMetadata", fixturePath("sui/sui_coin_metadata.json")))
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	detail, err := contract.FetchRedPacketCloseDetail(fixtureSuiCloseDigest)
+	require.Nil(t, err)
+	require.Equal(t, base.TransactionStatusFailure, detail.Status)
+	require.Equal(t, fixtureSuiPacket, detail.PacketObjectId)
+	require.Empty(t, detail.Creator)
+	require.Equal(t, "0", detail.RefundAmount)
+	require.NotNil(t, detail.Abort)
+	require.Equal(t, "EREDPACKET_PERMISSION_DENIED", detail.Abort.Name)
+}
@@ -0,0 +1,128 @@
+package redpacket
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+)
+
+// CreateQuote 发红包的费用明细，金额都是代币的最小单位
+type CreateQuote struct {
+	TokenAddress    string // 红包代币
+	RedPacketAmount string // 用户能够抢的那部分的 Amount
+	ServiceFee      string // 合约服务费
+	ServiceFeeToken string // eth 为链原生币，aptos/sui 为红包代币
+	GasFee          string
+	GasFeeToken     string // 链原生币
+	// gas 无法估算时为 false，例如余额或 eth erc20 授权不足时交易会失败，此时 GasFee 为 aptos/sui 交易的最大 gas fee，
+	// eth 没有上限，GasFee 只是按默认 gasLimit 的估算值，实际可能更高，Sufficient 仅供参考
+	GasEstimated bool
+	Debits       []*TokenDebit // 每种代币需要扣除的总额
+	Sufficient   bool          // 账户余额是否足够支付所有的 Debits
+}
+
+// TokenDebit 发红包需要扣除的一种代币的总额，以及账户的余额
+type TokenDebit struct {
+	TokenAddress string
+	Amount       string
+	Balance      string
+	Sufficient   bool
+}
+
+// createQuoter the chain specific parts of the create quote
+type createQuoter interface {
+	EstimateFeeWithContext(ctx context.Context, rpa *RedPacketAction) (string, error)
+	EstimateGasFeeWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (string, error)
+	// unestimatedGasFee the gas fee used when the gas can't be estimated,
+	// it's the max gas fee of the aptos/sui transaction, but only an estimate for eth
+	unestimatedGasFee(ctx context.Context) (*big.Int, error)
+	// quoteTokens the native coin of the gas fee, and the token of the service fee
+	quoteTokens(rpa *RedPacketAction) (gasToken string, feeToken string)
+	sameToken(a, b string) bool
+	balanceOf(ctx context.Context, owner, token string) (*big.Int, error)
+}
+
+// quoteCreate sum the red packet amount, the service fee and the gas fee by token, and check the balances of account
+func quoteCreate(ctx context.Context, quoter createQuoter, account base.Account, rpa *RedPacketAction) (*CreateQuote, error) {
+	if rpa.Method != RPAMethodCreate || rpa.CreateParams == nil {
+		return nil, newInvalidParamsError("invalid create params")
+	}
+	amount, ok := big.NewInt(0).SetString(rpa.CreateParams.Amount, 10)
+	if !ok {
+		return nil, newInvalidParamsError("invalid red packet amount %v", rpa.CreateParams.Amount)
+	}
+	fee, err := quoter.EstimateFeeWithContext(ctx, rpa)
+	if err != nil {
+		return nil, err
+	}
+	feeInt, ok := big.NewInt(0).SetString(fee, 10)
+	if !ok {
+		return nil, newRedPacketDataError("invalid service fee " + fee)
+	}
+
+	gasEstimated := true
+	var gasInt *big.Int
+	gasFee, err := quoter.EstimateGasFeeWithContext(ctx, account, rpa)
+	switch {
+	case err == nil:
+		gasInt, ok = big.NewInt(0).SetString(gasFee, 10)
+		if !ok {
+			return nil, newRedPacketDataError("invalid gas fee " + gasFee)
+		}
+	case errors.Is(err, ErrInsufficientBalance) || errors.Is(err, ErrContractAbort):
+		// the create will fail, e.g. the balance or the eth allowance is not enough
+		gasEstimated = false
+		gasInt, err = quoter.unestimatedGasFee(ctx)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	gasToken, feeToken := quoter.quoteTokens(rpa)
+	quote := &CreateQuote{
+		TokenAddress:    rpa.CreateParams.TokenAddress,
+		RedPacketAmount: amount.String(),
+		ServiceFee:      feeInt.String(),
+		ServiceFeeToken: feeToken,
+		GasFee:          gasInt.String(),
+		GasFeeToken:     gasToken,
+		GasEstimated:    gasEstimated,
+		Sufficient:      true,
+	}
+
+	var tokens []string
+	var amounts []*big.Int
+	debit := func(token string, amount *big.Int) {
+		for i := range tokens {
+			if quoter.sameToken(tokens[i], token) {
+				amounts[i].Add(amounts[i], amount)
+				return
+			}
+		}
+		tokens = append(tokens, token)
+		amounts = append(amounts, big.NewInt(0).Set(amount))
+	}
+	debit(quote.TokenAddress, amount)
+	debit(feeToken, feeInt)
+	debit(gasToken, gasInt)
+
+	for i, token := range tokens {
+		balance, err := quoter.balanceOf(ctx, account.Address(), token)
+		if err != nil {
+			return nil, err
+		}
+		sufficient := balance.Cmp(amounts[i]) >= 0
+		quote.Sufficient = quote.Sufficient && sufficient
+		quote.Debits = append(quote.Debits, &TokenDebit{
+			TokenAddress: token,
+			Amount:       amounts[i].String(),
+			Balance:      balance.String(),
+			Sufficient:   sufficient,
+		})
+	}
+	return quote, nil
+}
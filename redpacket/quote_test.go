@@ -0,0 +1,92 @@
+package redpacket
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	quoteNative = "native"
+	quoteToken  = "token"
+)
+
+// fakeQuoter charge the service fee in the native coin like eth
+type fakeQuoter struct {
+	gasErr   error
+	balances map[string]int64
+}
+
+func (f *fakeQuoter) EstimateFeeWithContext(ctx context.Context, rpa *RedPacketAction) (string, error) {
+	return "25", nil
+}
+
+func (f *fakeQuoter) EstimateGasFeeWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (string, error) {
+	if f.gasErr != nil {
+		return "", f.gasErr
+	}
+	return "10", nil
+}
+
+func (f *fakeQuoter) unestimatedGasFee(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(100), nil
+}
+
+func (f *fakeQuoter) quoteTokens(rpa *RedPacketAction) (string, string) {
+	return quoteNative, quoteNative
+}
+
+func (f *fakeQuoter) sameToken(a, b string) bool {
+	return a == b
+}
+
+func (f *fakeQuoter) balanceOf(ctx context.Context, owner, token string) (*big.Int, error) {
+	return big.NewInt(f.balances[token]), nil
+}
+
+type quoteAccount struct {
+	base.Account
+}
+
+func (quoteAccount) Address() string {
+	return "0x1"
+}
+
+func TestQuoteCreate(t *testing.T) {
+	quoter := &fakeQuoter{balances: map[string]int64{quoteNative: 35, quoteToken: 1000}}
+	rpa, err := NewRedPacketActionCreate(quoteToken, 2, "1000")
+	require.Nil(t, err)
+	quote, err := quoteCreate(context.Background(), quoter, quoteAccount{}, rpa)
+	require.Nil(t, err)
+	require.True(t, quote.GasEstimated)
+	require.Equal(t, "1000", quote.RedPacketAmount)
+	require.Equal(t, []*TokenDebit{
+		{TokenAddress: quoteToken, Amount: "1000", Balance: "1000", Sufficient: true},
+		{TokenAddress: quoteNative, Amount: "35", Balance: "35", Sufficient: true},
+	}, quote.Debits)
+	require.True(t, quote.Sufficient)
+
+	// the native red packet merge the amount, service fee and gas fee
+	rpa.CreateParams.TokenAddress = quoteNative
+	quote, err = quoteCreate(context.Background(), quoter, quoteAccount{}, rpa)
+	require.Nil(t, err)
+	require.Len(t, quote.Debits, 1)
+	require.Equal(t, "1035", quote.Debits[0].Amount)
+	require.False(t, quote.Sufficient)
+
+	// the failed estimate use the max gas fee
+	quoter.gasErr = &ContractAbortError{Reason: "ERC20: insufficient allowance", Code: ErrorCodeInsufficientBalance}
+	quote, err = quoteCreate(context.Background(), quoter, quoteAccount{}, rpa)
+	require.Nil(t, err)
+	require.False(t, quote.GasEstimated)
+	require.Equal(t, "100", quote.GasFee)
+	require.Equal(t, "1125", quote.Debits[0].Amount)
+
+	quoter.gasErr = networkError(io.EOF)
+	_, err = quoteCreate(context.Background(), quoter, quoteAccount{}, rpa)
+	require.ErrorIs(t, err, ErrNetwork)
+}
@@ -0,0 +1,457 @@
+package redpackettest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/coming-chat/go-red-packet/redpacket"
+	"github.com/coming-chat/wallet-SDK/core/base"
+)
+
+const ChainName = "simulator"
+
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// Contract implement redpacket.RedPacketContractCtx with the Simulator,
+// the transaction is executed when it's sent, the failed transaction return the error and is not recorded.
+// The packet id and the sui style packet object id are both supported.
+type Contract struct {
+	Simulator *Simulator
+	GasFee    string // returned by EstimateGasFee and set to the transaction fee, default "0"
+
+	mu           sync.Mutex
+	transactions map[string]*transaction
+}
+
+var _ redpacket.RedPacketContractCtx = (*Contract)(nil)
+
+type transaction struct {
+	detail *base.TransactionDetail
+	method string
+	packet Packet
+	action *redpacket.RedPacketAction
+	refund uint64
+}
+
+// unsignedTransaction the Data of the UnsignedTransaction built by the Contract
+type unsignedTransaction struct {
+	Sender string
+	Action *redpacket.RedPacketAction
+}
+
+func NewContract(simulator *Simulator) *Contract {
+	return &Contract{
+		Simulator:    simulator,
+		GasFee:       "0",
+		transactions: make(map[string]*transaction),
+	}
+}
+
+func (c *Contract) SendTransaction(account base.Account, rpa *redpacket.RedPacketAction) (string, error) {
+	return c.SendTransactionWithContext(context.Background(), account, rpa)
+}
+
+func (c *Contract) SendTransactionWithContext(ctx context.Context, account base.Account, rpa *redpacket.RedPacketAction) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return c.execute(account.Address(), rpa)
+}
+
+func invalidParamsError(message string) error {
+	return &redpacket.RedPacketError{Code: redpacket.ErrorCodeInvalidParams, Message: message}
+}
+
+// execute run the action on the simulator and record the transaction
+func (c *Contract) execute(sender string, rpa *redpacket.RedPacketAction) (string, error) {
+	tx := &transaction{
+		method: rpa.Method,
+		action: rpa,
+	}
+	switch rpa.Method {
+	case redpacket.RPAMethodCreate:
+		if rpa.CreateParams == nil {
+			return "", invalidParamsError("invalid create params")
+		}
+		amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
+		if err != nil {
+			return "", invalidParamsError("amount params is not uint64")
+		}
+		total := c.Simulator.CalcTotal(amount)
+		tx.packet, err = c.Simulator.Create(sender, rpa.CreateParams.TokenAddress, rpa.CreateParams.Count, total)
+		if err != nil {
+			return "", err
+		}
+		tx.detail = c.newDetail(sender, strconv.FormatUint(total, 10))
+	case redpacket.RPAMethodOpen:
+		if rpa.OpenParams == nil {
+			return "", invalidParamsError("invalid open params")
+		}
+		id, err := c.packetId(rpa.OpenParams.PacketId, rpa.OpenParams.PacketObjectId)
+		if err != nil {
+			return "", err
+		}
+		amounts := make([]uint64, len(rpa.OpenParams.Amounts))
+		for i, amount := range rpa.OpenParams.Amounts {
+			amounts[i], err = strconv.ParseUint(amount, 10, 64)
+			if err != nil {
+				return "", invalidParamsError("open amounts error")
+			}
+		}
+		tx.packet, err = c.Simulator.Open(sender, id, rpa.OpenParams.Addresses, amounts)
+		if err != nil {
+			return "", err
+		}
+		tx.detail = c.newDetail(sender, sumAmounts(amounts))
+	case redpacket.RPAMethodClose:
+		if rpa.CloseParams == nil {
+			return "", invalidParamsError("invalid close params")
+		}
+		id, err := c.packetId(rpa.CloseParams.PacketId, rpa.CloseParams.PacketObjectId)
+		if err != nil {
+			return "", err
+		}
+		tx.packet, tx.refund, err = c.Simulator.Close(sender, id)
+		if err != nil {
+			return "", err
+		}
+		tx.detail = c.newDetail(sender, strconv.FormatUint(tx.refund, 10))
+	default:
+		return "", invalidParamsError("unsopported red packet method " + rpa.Method)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx.detail.HashString = fmt.Sprintf("0x%064x", len(c.transactions)+1)
+	c.transactions[tx.detail.HashString] = tx
+	return tx.detail.HashString, nil
+}
+
+func (c *Contract) newDetail(sender string, amount string) *base.TransactionDetail {
+	return &base.TransactionDetail{
+		FromAddress:  sender,
+		ToAddress:    ChainName,
+		Amount:       amount,
+		EstimateFees: c.GasFee,
+		Status:       base.TransactionStatusSuccess,
+	}
+}
+
+// packetId the sui style object id is preferred when it's not empty
+func (c *Contract) packetId(id int64, objectId string) (int64, error) {
+	if objectId == "" {
+		return id, nil
+	}
+	id, ok := c.Simulator.PacketIdOfObject(objectId)
+	if !ok {
+		return 0, ErrPacketNotFound
+	}
+	return id, nil
+}
+
+func (c *Contract) transaction(hash string, method string) (*transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, ok := c.transactions[hash]
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+	if tx.method != method {
+		return nil, fmt.Errorf("not %s transaction", method)
+	}
+	return tx, nil
+}
+
+func (c *Contract) FetchRedPacketCreationDetail(hash string) (*redpacket.RedPacketDetail, error) {
+	return c.FetchRedPacketCreationDetailWithContext(context.Background(), hash)
+}
+
+func (c *Contract) FetchRedPacketCreationDetailWithContext(ctx context.Context, hash string) (*redpacket.RedPacketDetail, error) {
+	tx, err := c.transaction(hash, redpacket.RPAMethodCreate)
+	if err != nil {
+		return nil, err
+	}
+	detail := *tx.detail
+	return &redpacket.RedPacketDetail{
+		TransactionDetail: &detail,
+		AmountName:        tx.packet.Token,
+		RedPacketAmount:   strconv.FormatUint(tx.packet.RemainBalance, 10),
+		ChainName:         ChainName,
+		PacketId:          tx.packet.Id,
+		PacketObjectId:    tx.packet.ObjectId,
+	}, nil
+}
+
+func (c *Contract) FetchRedPacketOpenDetail(hash string) (*redpacket.RedPacketOpenDetail, error) {
+	return c.FetchRedPacketOpenDetailWithContext(context.Background(), hash)
+}
+
+func (c *Contract) FetchRedPacketOpenDetailWithContext(ctx context.Context, hash string) (*redpacket.RedPacketOpenDetail, error) {
+	tx, err := c.transaction(hash, redpacket.RPAMethodOpen)
+	if err != nil {
+		return nil, err
+	}
+	detail := *tx.detail
+	return &redpacket.RedPacketOpenDetail{
+		TransactionDetail: &detail,
+		AmountName:        tx.packet.Token,
+		ChainName:         ChainName,
+		PacketId:          tx.packet.Id,
+		PacketObjectId:    tx.packet.ObjectId,
+		Addresses:         append([]string{}, tx.action.OpenParams.Addresses...),
+		Amounts:           append([]string{}, tx.action.OpenParams.Amounts...),
+		RemainCount:       tx.packet.RemainCount,
+		RemainBalance:     strconv.FormatUint(tx.packet.RemainBalance, 10),
+	}, nil
+}
+
+func (c *Contract) FetchRedPacketCloseDetail(hash string) (*redpacket.RedPacketCloseDetail, error) {
+	return c.FetchRedPacketCloseDetailWithContext(context.Background(), hash)
+}
+
+func (c *Contract) FetchRedPacketCloseDetailWithContext(ctx context.Context, hash string) (*redpacket.RedPacketCloseDetail, error) {
+	tx, err := c.transaction(hash, redpacket.RPAMethodClose)
+	if err != nil {
+		return nil, err
+	}
+	detail := *tx.detail
+	return &redpacket.RedPacketCloseDetail{
+		TransactionDetail: &detail,
+		AmountName:        tx.packet.Token,
+		ChainName:         ChainName,
+		PacketId:          tx.packet.Id,
+		PacketObjectId:    tx.packet.ObjectId,
+		Creator:           tx.packet.Creator,
+		RefundAmount:      strconv.FormatUint(tx.refund, 10),
+	}, nil
+}
+
+func (c *Contract) EstimateFee(rpa *redpacket.RedPacketAction) (string, error) {
+	return c.EstimateFeeWithContext(context.Background(), rpa)
+}
+
+func (c *Contract) EstimateFeeWithContext(ctx context.Context, rpa *redpacket.RedPacketAction) (string, error) {
+	if rpa.Method != redpacket.RPAMethodCreate || rpa.CreateParams == nil {
+		return "", invalidParamsError("method invalid")
+	}
+	amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
+	if err != nil {
+		return "", invalidParamsError("amount params is not uint64")
+	}
+	return strconv.FormatUint(c.Simulator.CalcTotal(amount)-amount, 10), nil
+}
+
+func (c *Contract) EstimateGasFee(account base.Account, rpa *redpacket.RedPacketAction) (string, error) {
+	return c.EstimateGasFeeWithContext(context.Background(), account, rpa)
+}
+
+func (c *Contract) EstimateGasFeeWithContext(ctx context.Context, account base.Account, rpa *redpacket.RedPacketAction) (string, error) {
+	return c.GasFee, nil
+}
+
+func (c *Contract) FetchRedPacketState(tokenAddress string, packetId int64, packetObjectId string) (*redpacket.RedPacketState, error) {
+	return c.FetchRedPacketStateWithContext(context.Background(), tokenAddress, packetId, packetObjectId)
+}
+
+func (c *Contract) FetchRedPacketStateWithContext(ctx context.Context, tokenAddress string, packetId int64, packetObjectId string) (*redpacket.RedPacketState, error) {
+	id, err := c.packetId(packetId, packetObjectId)
+	if err != nil {
+		return nil, err
+	}
+	packet, ok := c.Simulator.Packet(id)
+	if !ok {
+		return nil, ErrPacketNotFound
+	}
+	if packet.Closed {
+		return nil, ErrPacketClosed
+	}
+	return &redpacket.RedPacketState{
+		TokenAddress:  packet.Token,
+		Creator:       packet.Creator,
+		RemainCount:   packet.RemainCount,
+		RemainBalance: strconv.FormatUint(packet.RemainBalance, 10),
+		Valid:         packet.RemainCount > 0,
+	}, nil
+}
+
+func (c *Contract) PrepareCreate(owner string, rpa *redpacket.RedPacketAction) (*redpacket.RedPacketAction, error) {
+	return c.PrepareCreateWithContext(context.Background(), owner, rpa)
+}
+
+// PrepareCreateWithContext the simulator has no approve
+func (c *Contract) PrepareCreateWithContext(ctx context.Context, owner string, rpa *redpacket.RedPacketAction) (*redpacket.RedPacketAction, error) {
+	if rpa.Method != redpacket.RPAMethodCreate || rpa.CreateParams == nil {
+		return nil, invalidParamsError("invalid create params")
+	}
+	return nil, nil
+}
+
+func (c *Contract) BuildUnsignedTransaction(sender string, rpa *redpacket.RedPacketAction) (*redpacket.UnsignedTransaction, error) {
+	return c.BuildUnsignedTransactionWithContext(context.Background(), sender, rpa)
+}
+
+// BuildUnsignedTransactionWithContext the Data is the json of sender and action, the signature is not checked
+func (c *Contract) BuildUnsignedTransactionWithContext(ctx context.Context, sender string, rpa *redpacket.RedPacketAction) (*redpacket.UnsignedTransaction, error) {
+	data, err := json.Marshal(unsignedTransaction{Sender: sender, Action: rpa})
+	if err != nil {
+		return nil, err
+	}
+	return &redpacket.UnsignedTransaction{
+		ChainName:      ChainName,
+		Sender:         sender,
+		Data:           data,
+		SigningMessage: data,
+		EstimateGasFee: c.GasFee,
+	}, nil
+}
+
+func (c *Contract) SubmitSignedTransaction(signedTx *redpacket.SignedTransaction) (string, error) {
+	return c.SubmitSignedTransactionWithContext(context.Background(), signedTx)
+}
+
+func (c *Contract) SubmitSignedTransactionWithContext(ctx context.Context, signedTx *redpacket.SignedTransaction) (string, error) {
+	if signedTx == nil {
+		return "", invalidParamsError("invalid signed transaction")
+	}
+	var tx unsignedTransaction
+	if err := json.Unmarshal(signedTx.Data, &tx); err != nil || tx.Action == nil {
+		return "", invalidParamsError("invalid signed transaction")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return c.execute(tx.Sender, tx.Action)
+}
+
+func (c *Contract) SimulateAction(account base.Account, rpa *redpacket.RedPacketAction) (*redpacket.SimulateResult, error) {
+	return c.SimulateActionWithContext(context.Background(), account, rpa)
+}
+
+// SimulateActionWithContext execute the action on a clone of the Simulator, the state isn't changed.
+// The errors of the contract rules are returned as the failed result, the invalid params are returned as error.
+func (c *Contract) SimulateActionWithContext(ctx context.Context, account base.Account, rpa *redpacket.RedPacketAction) (*redpacket.SimulateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sim := c.Simulator.Clone()
+	dryRun := NewContract(sim)
+	hash, err := dryRun.execute(account.Address(), rpa)
+	if err != nil {
+		abort := contractAbort(err)
+		if abort == nil {
+			return nil, err
+		}
+		return &redpacket.SimulateResult{
+			FailureMessage: err.Error(),
+			Abort:          abort,
+			EstimateGasFee: c.GasFee,
+			BalanceChanges: []*redpacket.BalanceChange{},
+			Events:         []*redpacket.RedPacketEvent{},
+		}, nil
+	}
+	tx := dryRun.transactions[hash]
+	return &redpacket.SimulateResult{
+		Success:        true,
+		EstimateGasFee: c.GasFee,
+		BalanceChanges: c.Simulator.balanceChanges(sim),
+		Events: []*redpacket.RedPacketEvent{{
+			Method:         tx.method,
+			PacketId:       tx.packet.Id,
+			PacketObjectId: tx.packet.ObjectId,
+			RemainCount:    tx.packet.RemainCount,
+			RemainBalance:  strconv.FormatUint(tx.packet.RemainBalance, 10),
+		}},
+	}, nil
+}
+
+// contractAbort convert the simulator error to the abort of the real contract, nil if it's not a contract rule
+func contractAbort(err error) *redpacket.ContractAbortError {
+	var abort *redpacket.ContractAbortError
+	if errors.As(err, &abort) {
+		return abort
+	}
+	for _, ruleErr := range []error{ErrPacketNotFound, ErrPacketClosed, ErrInvalidCount, ErrInsufficientBalance,
+		ErrCountExceeded, ErrBalanceExceeded, ErrAddressAmountMismatch} {
+		if err == ruleErr {
+			codeErr := ruleErr.(*redpacket.RedPacketError)
+			return &redpacket.ContractAbortError{Reason: codeErr.Message, Code: codeErr.Code}
+		}
+	}
+	return nil
+}
+
+func (c *Contract) QuoteCreate(account base.Account, rpa *redpacket.RedPacketAction) (*redpacket.CreateQuote, error) {
+	return c.QuoteCreateWithContext(context.Background(), account, rpa)
+}
+
+// QuoteCreateWithContext the service fee is charged in the red packet token,
+// the simulator doesn't charge the gas, so only the red packet token is debited.
+func (c *Contract) QuoteCreateWithContext(ctx context.Context, account base.Account, rpa *redpacket.RedPacketAction) (*redpacket.CreateQuote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if rpa.Method != redpacket.RPAMethodCreate || rpa.CreateParams == nil {
+		return nil, invalidParamsError("invalid create params")
+	}
+	amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
+	if err != nil {
+		return nil, invalidParamsError("amount params is not uint64")
+	}
+	token := rpa.CreateParams.TokenAddress
+	total := c.Simulator.CalcTotal(amount)
+	balance := c.Simulator.Balance(token, account.Address())
+	sufficient := balance >= total
+	return &redpacket.CreateQuote{
+		TokenAddress:    token,
+		RedPacketAmount: strconv.FormatUint(amount, 10),
+		ServiceFee:      strconv.FormatUint(total-amount, 10),
+		ServiceFeeToken: token,
+		GasFee:          c.GasFee,
+		GasEstimated:    true,
+		Debits: []*redpacket.TokenDebit{{
+			TokenAddress: token,
+			Amount:       strconv.FormatUint(total, 10),
+			Balance:      strconv.FormatUint(balance, 10),
+			Sufficient:   sufficient,
+		}},
+		Sufficient: sufficient,
+	}, nil
+}
+
+// WaitForConfirmation the transaction is confirmed when it's sent, the unknown hash is dropped
+func (c *Contract) WaitForConfirmation(ctx context.Context, hash string, opts *redpacket.ConfirmOptions) (*redpacket.RedPacketConfirmation, error) {
+	c.mu.Lock()
+	tx, ok := c.transactions[hash]
+	c.mu.Unlock()
+	if !ok {
+		return nil, &redpacket.TransactionDroppedError{Hash: hash}
+	}
+	detail := *tx.detail
+	confirmation := &redpacket.RedPacketConfirmation{Transaction: &detail}
+	if opts == nil {
+		return confirmation, nil
+	}
+	confirmation.Method = opts.Method
+	var err error
+	switch opts.Method {
+	case redpacket.RPAMethodCreate:
+		confirmation.Create, err = c.FetchRedPacketCreationDetailWithContext(ctx, hash)
+	case redpacket.RPAMethodOpen:
+		confirmation.Open, err = c.FetchRedPacketOpenDetailWithContext(ctx, hash)
+	case redpacket.RPAMethodClose:
+		confirmation.Close, err = c.FetchRedPacketCloseDetailWithContext(ctx, hash)
+	}
+	return confirmation, err
+}
+
+func sumAmounts(amounts []uint64) string {
+	total := uint64(0)
+	for _, amount := range amounts {
+		total += amount
+	}
+	return strconv.FormatUint(total, 10)
+}
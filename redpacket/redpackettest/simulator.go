@@ -0,0 +1,282 @@
+// Package redpackettest provide an in-memory simulator of the red packet contract rules,
+// and a RedPacketContract backed by it, so the red packet flows can be tested offline and deterministically.
+// The amounts are uint64 as the aptos/sui contract.
+package redpackettest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/coming-chat/go-red-packet/redpacket"
+)
+
+const DefaultFeePoint = 250
+
+// the errors have the same code as the real contracts, e.g. errors.Is(ErrCountExceeded, redpacket.ErrPacketExhausted)
+var (
+	ErrPacketNotFound        = simulatorError(redpacket.ErrorCodePacketNotFound, "red packet not found")
+	ErrPacketClosed          = simulatorError(redpacket.ErrorCodePacketClosed, "red packet is closed")
+	ErrInvalidCount          = simulatorError(redpacket.ErrorCodeInvalidParams, "invalid red packet count")
+	ErrInsufficientBalance   = simulatorError(redpacket.ErrorCodeInsufficientBalance, "insufficient balance")
+	ErrCountExceeded         = simulatorError(redpacket.ErrorCodePacketExhausted, "the number of opened addresses exceeds the remain count")
+	ErrBalanceExceeded       = simulatorError(redpacket.ErrorCodePacketExhausted, "the opened amount exceeds the remain balance")
+	ErrNotAdmin              = error(&redpacket.ContractAbortError{Reason: "the sender is not the admin"})
+	ErrAddressAmountMismatch = simulatorError(redpacket.ErrorCodeInvalidParams, "the number of opened addresses is not the same as the amount")
+)
+
+func simulatorError(code redpacket.ErrorCode, message string) error {
+	return &redpacket.RedPacketError{Code: code, Message: message}
+}
+
+// Packet the state of the simulated red packet
+type Packet struct {
+	Id            int64
+	ObjectId      string // the sui style object id, derived from Id
+	Token         string
+	Creator       string
+	RemainCount   int64
+	RemainBalance uint64
+	Closed        bool
+}
+
+// Simulator simulate the red packet contract rules, it's safe for concurrent use.
+//   - create: the creator pay the total balance, total / 10000 * FeePoint is deducted as the fee
+//   - open: the amounts are transferred to the addresses, checked by the remain count and balance
+//   - close: the remain balance is refunded to the creator
+type Simulator struct {
+	FeePoint uint64 // 0 means DefaultFeePoint
+	MaxCount int64  // the max count of a red packet, 0 means no limit
+	Admin    string // only the admin can open and close when it's not empty
+
+	mu       sync.Mutex
+	nextId   int64
+	packets  map[int64]*Packet
+	balances map[string]map[string]uint64 // token => address => balance
+	fees     map[string]uint64            // token => collected fee
+}
+
+func NewSimulator(feePoint uint64) *Simulator {
+	return &Simulator{
+		FeePoint: feePoint,
+		packets:  make(map[int64]*Packet),
+		balances: make(map[string]map[string]uint64),
+		fees:     make(map[string]uint64),
+	}
+}
+
+func (s *Simulator) feePoint() uint64 {
+	if s.FeePoint == 0 {
+		return DefaultFeePoint
+	}
+	return s.FeePoint
+}
+
+// CalcTotal the total balance to send for the red packet amount, same as the real contract client
+func (s *Simulator) CalcTotal(amount uint64) uint64 {
+	return redpacket.CalcTotal(amount, s.feePoint())
+}
+
+// SetBalance set the token balance of the address, the creator must have the balance before create
+func (s *Simulator) SetBalance(token, address string, balance uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenBalances(token)[address] = balance
+}
+
+func (s *Simulator) Balance(token, address string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances[token][address]
+}
+
+// CollectedFee the fee of the token collected by the contract
+func (s *Simulator) CollectedFee(token string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fees[token]
+}
+
+// Packet return a copy of the packet state
+func (s *Simulator) Packet(id int64) (Packet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	packet, ok := s.packets[id]
+	if !ok {
+		return Packet{}, false
+	}
+	return *packet, true
+}
+
+// PacketIdOfObject find the packet id by the sui style object id
+func (s *Simulator) PacketIdOfObject(objectId string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, packet := range s.packets {
+		if packet.ObjectId == objectId {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Clone return a deep copy of the simulator, the copy is changed independently, e.g. to dry-run the actions
+func (s *Simulator) Clone() *Simulator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clone := NewSimulator(s.FeePoint)
+	clone.MaxCount = s.MaxCount
+	clone.Admin = s.Admin
+	clone.nextId = s.nextId
+	for id, packet := range s.packets {
+		p := *packet
+		clone.packets[id] = &p
+	}
+	for token, balances := range s.balances {
+		cloneBalances := clone.tokenBalances(token)
+		for address, balance := range balances {
+			cloneBalances[address] = balance
+		}
+	}
+	for token, fee := range s.fees {
+		clone.fees[token] = fee
+	}
+	return clone
+}
+
+// balanceChanges the balances of other minus the balances of s, sorted by token and address
+func (s *Simulator) balanceChanges(other *Simulator) []*redpacket.BalanceChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	changes := []*redpacket.BalanceChange{}
+	for _, token := range sortedKeys(other.balances) {
+		balances := other.balances[token]
+		for _, address := range sortedKeys(balances) {
+			before, after := s.balances[token][address], balances[address]
+			if before == after {
+				continue
+			}
+			amount := strconv.FormatUint(after-before, 10)
+			if after < before {
+				amount = "-" + strconv.FormatUint(before-after, 10)
+			}
+			changes = append(changes, &redpacket.BalanceChange{Address: address, TokenAddress: token, Amount: amount})
+		}
+	}
+	return changes
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Create create the red packet with the total balance include the fee, return the created packet
+func (s *Simulator) Create(creator, token string, count int, totalBalance uint64) (Packet, error) {
+	if count <= 0 || (s.MaxCount > 0 && int64(count) > s.MaxCount) {
+		return Packet{}, ErrInvalidCount
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balances := s.tokenBalances(token)
+	if balances[creator] < totalBalance {
+		return Packet{}, ErrInsufficientBalance
+	}
+	fee := totalBalance / 10000 * s.feePoint()
+	balances[creator] -= totalBalance
+	s.fees[token] += fee
+
+	id := s.nextId
+	s.nextId++
+	packet := &Packet{
+		Id:            id,
+		ObjectId:      fmt.Sprintf("0x%064x", id),
+		Token:         token,
+		Creator:       creator,
+		RemainCount:   int64(count),
+		RemainBalance: totalBalance - fee,
+	}
+	s.packets[id] = packet
+	return *packet, nil
+}
+
+// Open transfer the amounts to the addresses, all or nothing
+func (s *Simulator) Open(sender string, id int64, addresses []string, amounts []uint64) (Packet, error) {
+	if len(addresses) != len(amounts) {
+		return Packet{}, ErrAddressAmountMismatch
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	packet, err := s.adminPacket(sender, id)
+	if err != nil {
+		return Packet{}, err
+	}
+	if int64(len(addresses)) > packet.RemainCount {
+		return Packet{}, ErrCountExceeded
+	}
+	total := uint64(0)
+	for _, amount := range amounts {
+		if total+amount < total {
+			return Packet{}, ErrBalanceExceeded
+		}
+		total += amount
+	}
+	if total > packet.RemainBalance {
+		return Packet{}, ErrBalanceExceeded
+	}
+
+	balances := s.tokenBalances(packet.Token)
+	for i, address := range addresses {
+		balances[address] += amounts[i]
+	}
+	packet.RemainCount -= int64(len(addresses))
+	packet.RemainBalance -= total
+	return *packet, nil
+}
+
+// Close refund the remain balance to the creator, return the refund amount
+func (s *Simulator) Close(sender string, id int64) (Packet, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	packet, err := s.adminPacket(sender, id)
+	if err != nil {
+		return Packet{}, 0, err
+	}
+	refund := packet.RemainBalance
+	s.tokenBalances(packet.Token)[packet.Creator] += refund
+	packet.RemainBalance = 0
+	packet.Closed = true
+	return *packet, refund, nil
+}
+
+// adminPacket check the sender and find the open packet, must be called with the lock
+func (s *Simulator) adminPacket(sender string, id int64) (*Packet, error) {
+	if s.Admin != "" && sender != s.Admin {
+		return nil, ErrNotAdmin
+	}
+	packet, ok := s.packets[id]
+	if !ok {
+		return nil, ErrPacketNotFound
+	}
+	if packet.Closed {
+		return nil, ErrPacketClosed
+	}
+	return packet, nil
+}
+
+// tokenBalances must be called with the lock
+func (s *Simulator) tokenBalances(token string) map[string]uint64 {
+	balances, ok := s.balances[token]
+	if !ok {
+		balances = make(map[string]uint64)
+		s.balances[token] = balances
+	}
+	return balances
+}
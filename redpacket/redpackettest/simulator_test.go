@@ -0,0 +1,180 @@
+package redpackettest
+
+import (
+	"testing"
+
+	"github.com/coming-chat/go-red-packet/redpacket"
+	"github.com/coming-chat/wallet-SDK/core/base"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken   = "0x1::aptos_coin::AptosCoin"
+	testCreator = "0xc1"
+	testAlice   = "0xa1"
+	testBob     = "0xb1"
+)
+
+// testAccount only the address is used by the Contract
+type testAccount struct {
+	base.Account
+	address string
+}
+
+func (a testAccount) Address() string {
+	return a.address
+}
+
+func TestSimulator_CreateOpenClose(t *testing.T) {
+	sim := NewSimulator(DefaultFeePoint)
+	total := sim.CalcTotal(1000000)
+	sim.SetBalance(testToken, testCreator, total)
+
+	packet, err := sim.Create(testCreator, testToken, 3, total)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1000000), packet.RemainBalance)
+	require.Equal(t, total-1000000, sim.CollectedFee(testToken))
+	require.Equal(t, uint64(0), sim.Balance(testToken, testCreator))
+
+	_, err = sim.Open("", packet.Id, []string{testAlice, testBob}, []uint64{600000, 500000})
+	require.ErrorIs(t, err, ErrBalanceExceeded)
+	require.ErrorIs(t, err, redpacket.ErrPacketExhausted)
+	require.NotErrorIs(t, err, ErrCountExceeded)
+	_, err = sim.Open("", packet.Id, []string{testAlice, testBob, testAlice, testBob}, []uint64{1, 1, 1, 1})
+	require.ErrorIs(t, err, ErrCountExceeded)
+
+	packet, err = sim.Open("", packet.Id, []string{testAlice, testBob}, []uint64{600000, 300000})
+	require.Nil(t, err)
+	require.Equal(t, int64(1), packet.RemainCount)
+	require.Equal(t, uint64(100000), packet.RemainBalance)
+	require.Equal(t, uint64(600000), sim.Balance(testToken, testAlice))
+
+	packet, refund, err := sim.Close("", packet.Id)
+	require.Nil(t, err)
+	require.Equal(t, uint64(100000), refund)
+	require.True(t, packet.Closed)
+	require.Equal(t, uint64(100000), sim.Balance(testToken, testCreator))
+
+	_, err = sim.Open("", packet.Id, []string{testAlice}, []uint64{1})
+	require.ErrorIs(t, err, ErrPacketClosed)
+	require.Equal(t, redpacket.ErrorCodePacketClosed, redpacket.ErrorCodeOf(err))
+}
+
+func TestSimulator_InsufficientBalance(t *testing.T) {
+	sim := NewSimulator(DefaultFeePoint)
+	sim.SetBalance(testToken, testCreator, 100)
+	_, err := sim.Create(testCreator, testToken, 1, 101)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+}
+
+func TestContract_Flow(t *testing.T) {
+	sim := NewSimulator(DefaultFeePoint)
+	sim.Admin = testCreator
+	contract := NewContract(sim)
+	sim.SetBalance(testToken, testCreator, sim.CalcTotal(1000000))
+
+	create, err := redpacket.NewRedPacketActionCreate(testToken, 2, "1000000")
+	require.Nil(t, err)
+	fee, err := contract.EstimateFee(create)
+	require.Nil(t, err)
+	require.Equal(t, "25500", fee)
+	_, err = contract.EstimateFee(&redpacket.RedPacketAction{
+		Method:       redpacket.RPAMethodCreate,
+		CreateParams: &redpacket.RedPacketCreateParams{TokenAddress: testToken, Count: 2, Amount: "1e6"},
+	})
+	require.ErrorIs(t, err, redpacket.ErrInvalidParams)
+
+	unsigned, err := contract.BuildUnsignedTransaction(testCreator, create)
+	require.Nil(t, err)
+	hash, err := contract.SubmitSignedTransaction(&redpacket.SignedTransaction{Data: unsigned.Data})
+	require.Nil(t, err)
+	detail, err := contract.FetchRedPacketCreationDetail(hash)
+	require.Nil(t, err)
+	require.Equal(t, "1000000", detail.RedPacketAmount)
+
+	open, err := redpacket.NewSuiRedpacketActionOpen(testToken, detail.PacketObjectId, []string{testAlice}, []string{"400000"})
+	require.Nil(t, err)
+	hash, err = contract.execute(testCreator, open)
+	require.Nil(t, err)
+	openDetail, err := contract.FetchRedPacketOpenDetail(hash)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), openDetail.RemainCount)
+	require.Equal(t, "600000", openDetail.RemainBalance)
+
+	_, err = contract.execute(testAlice, open)
+	require.ErrorIs(t, err, ErrNotAdmin)
+
+	closeAction, err := redpacket.NewRedPacketActionClose(testToken, detail.PacketId, testCreator, "")
+	require.Nil(t, err)
+	hash, err = contract.execute(testCreator, closeAction)
+	require.Nil(t, err)
+	closeDetail, err := contract.FetchRedPacketCloseDetail(hash)
+	require.Nil(t, err)
+	require.Equal(t, "600000", closeDetail.RefundAmount)
+
+	_, err = contract.FetchRedPacketState(testToken, detail.PacketId, "")
+	require.ErrorIs(t, err, redpacket.ErrPacketClosed)
+}
+
+func TestContract_SimulateAction(t *testing.T) {
+	sim := NewSimulator(DefaultFeePoint)
+	contract := NewContract(sim)
+	total := sim.CalcTotal(1000000)
+	sim.SetBalance(testToken, testCreator, total)
+	creator := testAccount{address: testCreator}
+
+	create, err := redpacket.NewRedPacketActionCreate(testToken, 2, "1000000")
+	require.Nil(t, err)
+	result, err := contract.SimulateAction(creator, create)
+	require.Nil(t, err)
+	require.True(t, result.Success)
+	require.Len(t, result.BalanceChanges, 1)
+	require.Equal(t, "-1025500", result.BalanceChanges[0].Amount)
+	require.Len(t, result.Events, 1)
+	require.Equal(t, redpacket.RPAMethodCreate, result.Events[0].Method)
+	require.Equal(t, "1000000", result.Events[0].RemainBalance)
+	// the simulator state isn't changed
+	require.Equal(t, total, sim.Balance(testToken, testCreator))
+	_, ok := sim.Packet(0)
+	require.False(t, ok)
+
+	open, err := redpacket.NewRedPacketActionOpen(testToken, 0, []string{testAlice}, []string{"1"})
+	require.Nil(t, err)
+	result, err = contract.SimulateAction(creator, open)
+	require.Nil(t, err)
+	require.False(t, result.Success)
+	require.ErrorIs(t, result.Abort, redpacket.ErrPacketNotFound)
+
+	create.CreateParams.Amount = "invalid"
+	_, err = contract.SimulateAction(creator, create)
+	require.ErrorIs(t, err, redpacket.ErrInvalidParams)
+}
+
+func TestContract_QuoteCreate(t *testing.T) {
+	sim := NewSimulator(DefaultFeePoint)
+	contract := NewContract(sim)
+	sim.SetBalance(testToken, testCreator, 1025499)
+	creator := testAccount{address: testCreator}
+
+	create, err := redpacket.NewRedPacketActionCreate(testToken, 2, "1000000")
+	require.Nil(t, err)
+	quote, err := contract.QuoteCreate(creator, create)
+	require.Nil(t, err)
+	require.Equal(t, "1000000", quote.RedPacketAmount)
+	require.Equal(t, "25500", quote.ServiceFee)
+	require.Equal(t, testToken, quote.ServiceFeeToken)
+	require.Len(t, quote.Debits, 1)
+	require.Equal(t, "1025500", quote.Debits[0].Amount)
+	require.Equal(t, "1025499", quote.Debits[0].Balance)
+	require.False(t, quote.Sufficient)
+
+	sim.SetBalance(testToken, testCreator, 1025500)
+	quote, err = contract.QuoteCreate(creator, create)
+	require.Nil(t, err)
+	require.True(t, quote.Sufficient)
+
+	open, err := redpacket.NewRedPacketActionOpen(testToken, 0, []string{testAlice}, []string{"1"})
+	require.Nil(t, err)
+	_, err = contract.QuoteCreate(creator, open)
+	require.ErrorIs(t, err, redpacket.ErrInvalidParams)
+}
@@ -2,12 +2,14 @@ package redpacket
 
 import (
 	"context"
-	"errors"
+	"encoding/base64"
 	"fmt"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/coming-chat/go-sui/v2/client"
 	"github.com/coming-chat/go-sui/v2/lib"
 	"github.com/coming-chat/go-sui/v2/move_types"
 	"github.com/coming-chat/go-sui/v2/sui_types"
@@ -23,320 +25,1062 @@ const (
 	suiPackage     = "red_packet"
 	suiCoinAddress = "0x2::sui::SUI"
 
-	suiFeePoint = 250
+	// suiDefaultFeePoint is used when the config object has no fee_point
+	suiDefaultFeePoint = 250
+	// suiCoinPageLimit the max coins of a suix_getCoins page
+	suiCoinPageLimit = 50
+	// suiMaxGasPaymentCoins the protocol limit max_gas_payment_objects is 256
+	suiMaxGasPaymentCoins = 255
+	// suiMaxMergeCoins the max coins of one MergeCoins or MakeMoveVec command, the protocol limit max_arguments is 512
+	suiMaxMergeCoins = 255
+	// suiMaxInputCoins the max coins spent by one transaction, keep the transaction under the 128KB size limit
+	suiMaxInputCoins = 1000
 )
 
+// suiRedPacketConfig the shared config object of the red packet contract
+type suiRedPacketConfig struct {
+	InitialSharedVersion uint64
+	FeePoint             uint64
+	CoinFeePoints        map[string]uint64 // fee point of the coin type, override FeePoint
+}
+
+func (cfg *suiRedPacketConfig) feePointOf(coinType string) uint64 {
+	for coin, feePoint := range cfg.CoinFeePoints {
+		if suiSameCoinType(coin, coinType) {
+			return feePoint
+		}
+	}
+	return cfg.FeePoint
+}
+
+// SuiChain the sui chain methods used by the red packet contract, it's implemented by *sui.Chain,
+// the tests can create the chain with a stub rpc url or implement it with a fake.
+type SuiChain interface {
+	Client() (*client.Client, error)
+	CachedGasPrice() (uint64, error)
+	EstimateTransactionFeeAndRebuildTransactionBCS(maxGasBudget uint64, buildTransaction func(gasBudget uint64) (*sui.Transaction, error)) (*sui.Transaction, error)
+	SendRawTransaction(signedTx string) (string, error)
+}
+
+var _ SuiChain = (*sui.Chain)(nil)
+
 type suiRedPacketContract struct {
-	chain        *sui.Chain
+	chain        SuiChain
 	address      string
 	packageIdHex sui_types.SuiAddress
 	configHex    sui_types.ObjectID
 }
 
-func NewSuiRedPacketContract(chain *sui.Chain, contractAddress string, config *ContractConfig) (RedPacketContract, error) {
-	address := "0x" + strings.TrimPrefix(contractAddress, "0x")
-	pkgId, err := sui_types.NewAddressFromHex(address)
+var _ RedPacketBatchContract = (*suiRedPacketContract)(nil)
+
+func NewSuiRedPacketContract(chain SuiChain, contractAddress string, config *ContractConfig) (RedPacketContract, error) {
+	address := "0x" + strings.TrimPrefix(contractAddress, "0x")
+	pkgId, err := sui_types.NewAddressFromHex(address)
+	if err != nil {
+		return nil, err
+	}
+	configHex, err := sui_types.NewObjectIdFromHex(config.SuiConfigAddress)
+	if err != nil {
+		return nil, err
+	}
+	return &suiRedPacketContract{
+		chain:        chain,
+		address:      address,
+		packageIdHex: *pkgId,
+		configHex:    *configHex,
+	}, nil
+}
+
+func (c *suiRedPacketContract) SendTransaction(account base.Account, rpa *RedPacketAction) (string, error) {
+	return c.SendTransactionWithContext(context.Background(), account, rpa)
+}
+
+func (c *suiRedPacketContract) SendTransactionWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (string, error) {
+	tx, err := c.createTx(ctx, account.Address(), rpa)
+	if err != nil {
+		return "", err
+	}
+	return c.signAndSend(ctx, account, tx)
+}
+
+func (c *suiRedPacketContract) SendBatch(account base.Account, rpas []*RedPacketAction) (string, error) {
+	return c.SendBatchWithContext(context.Background(), account, rpas)
+}
+
+func (c *suiRedPacketContract) SendBatchWithContext(ctx context.Context, account base.Account, rpas []*RedPacketAction) (string, error) {
+	tx, err := c.buildBatchTx(ctx, account.Address(), rpas)
+	if err != nil {
+		return "", dryRunError(err)
+	}
+	return c.signAndSend(ctx, account, tx)
+}
+
+func (c *suiRedPacketContract) FetchBatchResult(hash string) (*BatchResult, error) {
+	return c.FetchBatchResultWithContext(context.Background(), hash)
+}
+
+// FetchBatchResultWithContext the move calls emit the RedPacketEvent in the order of the actions
+func (c *suiRedPacketContract) FetchBatchResultWithContext(ctx context.Context, hash string) (result *BatchResult, err error) {
+	defer base.CatchPanicAndMapToBasicError(&err)
+
+	resp, err := c.fetchTransactionBlock(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	detail := toSuiTransactionDetail(hash, resp)
+	result = &BatchResult{
+		TransactionDetail: detail,
+		Abort:             transactionAbort(detail),
+		Events:            []*RedPacketEvent{},
+	}
+	if detail.Status == base.TransactionStatusSuccess {
+		result.Events, err = suiRedPacketEvents(resp.Events)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (c *suiRedPacketContract) signAndSend(ctx context.Context, account base.Account, tx *sui.Transaction) (string, error) {
+	suiAccount, ok := account.(*sui.Account)
+	if !ok {
+		return "", newInvalidParamsError("invalid account object")
+	}
+
+	signedTxn, err := tx.SignWithAccount(suiAccount)
+	if err != nil {
+		return "", err
+	}
+	// wallet-SDK submit does not accept context, give up before the transaction is broadcast
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+	hash, err := c.chain.SendRawTransaction(signedTxn.Value)
+	if err != nil {
+		return "", networkError(err)
+	}
+	return hash, nil
+}
+
+// createTx build the transaction of the action, the abort of the dry-run is decoded to ContractAbortError
+func (c *suiRedPacketContract) createTx(ctx context.Context, senderAddress string, rpa *RedPacketAction) (*sui.Transaction, error) {
+	tx, err := c.buildTx(ctx, senderAddress, rpa)
+	return tx, dryRunError(err)
+}
+
+func (c *suiRedPacketContract) buildTx(ctx context.Context, senderAddress string, rpa *RedPacketAction) (*sui.Transaction, error) {
+	return c.buildBatchTx(ctx, senderAddress, []*RedPacketAction{rpa})
+}
+
+// suiBatchCall the parsed action of the batch transaction
+type suiBatchCall struct {
+	method  string
+	typeTag move_types.StructTag
+	// create: the coin to split the total from, the sui red packet is split from the gas coin
+	coinType string
+	total    uint64
+	count    uint64
+	// open/close
+	packetObjectId string
+	packet         sui_types.ObjectArg
+	addresses      []sui_types.SuiAddress
+	amounts        []uint64
+}
+
+// buildBatchTx build the actions into one programmable transaction. The coins of the same token are
+// merged into one coin and each create splits its total from it, the sui coins more than the gas payment
+// can take are merged into the gas coin.
+func (c *suiRedPacketContract) buildBatchTx(ctx context.Context, senderAddress string, rpas []*RedPacketAction) (*sui.Transaction, error) {
+	if len(rpas) == 0 {
+		return nil, newInvalidParamsError("no red packet action")
+	}
+	sender, err := sui_types.NewAddressFromHex(senderAddress)
+	if err != nil {
+		return nil, err
+	}
+	var config *suiRedPacketConfig
+	calls := make([]*suiBatchCall, len(rpas))
+	packets := make(map[string]sui_types.ObjectArg)
+	var suiTotal uint64
+	var tokens []string
+	tokenTotals := make(map[string]uint64)
+	for i, rpa := range rpas {
+		if rpa == nil {
+			return nil, newInvalidParamsError("red packet action is nil")
+		}
+		call := &suiBatchCall{method: rpa.Method}
+		switch rpa.Method {
+		case RPAMethodCreate:
+			if rpa.CreateParams == nil {
+				return nil, newInvalidParamsError("invalid create params")
+			}
+			if config == nil {
+				if config, err = c.fetchConfig(ctx); err != nil {
+					return nil, err
+				}
+			}
+			amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
+			if err != nil {
+				return nil, newInvalidParamsError("amount params is not uint64")
+			}
+			call.total = calcTotal(amount, config.feePointOf(rpa.CreateParams.TokenAddress))
+			call.count = uint64(rpa.CreateParams.Count)
+			call.coinType = rpa.CreateParams.TokenAddress
+			if suiSameCoinType(call.coinType, suiCoinAddress) {
+				call.coinType = suiCoinAddress
+				suiTotal += call.total
+			} else {
+				// the same coin type may be written with or without the leading zeros of the address
+				known := false
+				for _, token := range tokens {
+					if suiSameCoinType(token, call.coinType) {
+						call.coinType, known = token, true
+						break
+					}
+				}
+				if !known {
+					tokens = append(tokens, call.coinType)
+				}
+				tokenTotals[call.coinType] += call.total
+			}
+		case RPAMethodOpen:
+			if rpa.OpenParams == nil || len(rpa.OpenParams.PacketObjectId) == 0 {
+				return nil, newInvalidParamsError("invalid redPacketObjectId")
+			}
+			call.packetObjectId = rpa.OpenParams.PacketObjectId
+			if call.addresses, call.amounts, err = suiOpenArgs(rpa.OpenParams); err != nil {
+				return nil, err
+			}
+		case RPAMethodClose:
+			if rpa.CloseParams == nil || len(rpa.CloseParams.PacketObjectId) == 0 {
+				return nil, newInvalidParamsError("invalid redPacketObjectId")
+			}
+			call.packetObjectId = rpa.CloseParams.PacketObjectId
+		default:
+			return nil, newInvalidParamsError("unsopported red packet method %s", rpa.Method)
+		}
+		if call.typeTag, err = suiStructTag(rpa.TokenAddress()); err != nil {
+			return nil, err
+		}
+		if call.packetObjectId != "" {
+			packet, ok := packets[call.packetObjectId]
+			if !ok {
+				if packet, err = c.packetObjectArg(ctx, call.packetObjectId); err != nil {
+					return nil, err
+				}
+				packets[call.packetObjectId] = packet
+			}
+			call.packet = packet
+		}
+		calls[i] = call
+	}
+
+	tokenCoins := make(map[string][]types.Coin)
+	for _, token := range tokens {
+		coins, err := c.listCoins(ctx, *sender, token)
+		if err != nil {
+			return nil, err
+		}
+		picked, err := suiPickCoins(coins, tokenTotals[token], suiMaxInputCoins)
+		if err != nil {
+			return nil, err
+		}
+		tokenCoins[token] = picked.coins
+	}
+	gasCoins, err := c.listCoins(ctx, *sender, suiCoinAddress)
+	if err != nil {
+		return nil, err
+	}
+	pickedGasCoins, err := suiPickCoins(gasCoins, suiTotal+sui.MaxGasForPay, suiMaxInputCoins)
+	if err != nil {
+		return nil, err
+	}
+	gasPayment, gasMerged := pickedGasCoins.coins, []types.Coin(nil)
+	if len(gasPayment) > suiMaxGasPaymentCoins {
+		gasPayment, gasMerged = gasPayment[:suiMaxGasPaymentCoins], gasPayment[suiMaxGasPaymentCoins:]
+	}
+
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	maxGasBudget := base.Min(pickedGasCoins.total-suiTotal, sui.MaxGasForPay)
+	gasPrice, _ := c.chain.CachedGasPrice()
+
+	return c.chain.EstimateTransactionFeeAndRebuildTransactionBCS(maxGasBudget, func(gasBudget uint64) (*sui.Transaction, error) {
+		ptb := sui_types.NewProgrammableTransactionBuilder()
+		gasCoin := sui_types.Argument{GasCoin: &lib.EmptyEnum{}}
+		mergedArgs, err := suiCoinArgs(ptb, gasMerged)
+		if err != nil {
+			return nil, err
+		}
+		suiMergeCoins(ptb, gasCoin, mergedArgs)
+		coinArgs := map[string]sui_types.Argument{suiCoinAddress: gasCoin}
+		for _, token := range tokens {
+			args, err := suiCoinArgs(ptb, tokenCoins[token])
+			if err != nil {
+				return nil, err
+			}
+			suiMergeCoins(ptb, args[0], args[1:])
+			coinArgs[token] = args[0]
+		}
+		// the shared objects are added once, they are used by several calls
+		objectArgs := make(map[string]sui_types.Argument)
+		objectArg := func(id string, arg sui_types.ObjectArg) (sui_types.Argument, error) {
+			if argument, ok := objectArgs[id]; ok {
+				return argument, nil
+			}
+			argument, err := ptb.Obj(arg)
+			objectArgs[id] = argument
+			return argument, err
+		}
+		for _, call := range calls {
+			switch call.method {
+			case RPAMethodCreate:
+				configArg, err := objectArg(c.configHex.String(), suiSharedObjectArg(c.configHex, config.InitialSharedVersion))
+				if err != nil {
+					return nil, err
+				}
+				err = c.createCommand(ptb, call.typeTag, configArg, coinArgs[call.coinType], call.count, call.total)
+				if err != nil {
+					return nil, err
+				}
+			case RPAMethodOpen:
+				packetArg, err := objectArg(call.packetObjectId, call.packet)
+				if err != nil {
+					return nil, err
+				}
+				if err = c.openCommand(ptb, call.typeTag, packetArg, call.addresses, call.amounts); err != nil {
+					return nil, err
+				}
+			case RPAMethodClose:
+				packetArg, err := objectArg(call.packetObjectId, call.packet)
+				if err != nil {
+					return nil, err
+				}
+				c.moveCallCommand(ptb, "close", call.typeTag, packetArg)
+			}
+		}
+		tx := sui_types.NewProgrammable(*sender, suiCoinRefs(gasPayment), ptb.Finish(), gasBudget, gasPrice)
+		txBytes, err := bcs.Marshal(tx)
+		if err != nil {
+			return nil, err
+		}
+		return &sui.Transaction{TxnBytes: txBytes}, nil
+	})
+}
+
+// suiStructTag the type argument of the coin type
+func suiStructTag(coinType string) (move_types.StructTag, error) {
+	resourceType, err := types.NewResourceType(coinType)
+	if err != nil {
+		return move_types.StructTag{}, err
+	}
+	return move_types.StructTag{
+		Address: *resourceType.Address,
+		Module:  move_types.Identifier(resourceType.ModuleName),
+		Name:    move_types.Identifier(resourceType.FuncName),
+	}, nil
+}
+
+// moveCallCommand call the function of the red packet module with the coin type
+func (c *suiRedPacketContract) moveCallCommand(ptb *sui_types.ProgrammableTransactionBuilder, function string, typeTag move_types.StructTag, args ...sui_types.Argument) sui_types.Argument {
+	return ptb.Command(
+		sui_types.Command{
+			MoveCall: &sui_types.ProgrammableMoveCall{
+				Package:  c.packageIdHex,
+				Module:   move_types.Identifier(suiPackage),
+				Function: move_types.Identifier(function),
+				TypeArguments: []move_types.TypeTag{
+					{Struct: &typeTag},
+				},
+				Arguments: args,
+			},
+		},
+	)
+}
+
+// createCommand create(config: &mut Config, coins: vector<Coin<T>>, count: u64, total: u64), the coin of total is split from coinArg
+func (c *suiRedPacketContract) createCommand(ptb *sui_types.ProgrammableTransactionBuilder, typeTag move_types.StructTag,
+	configArg sui_types.Argument, coinArg sui_types.Argument, count uint64, total uint64) error {
+	totalArg, err := ptb.Pure(total)
+	if err != nil {
+		return err
+	}
+	countArg, err := ptb.Pure(count)
+	if err != nil {
+		return err
+	}
+	splitArg := ptb.Command(
+		sui_types.Command{
+			SplitCoins: &struct {
+				Argument  sui_types.Argument
+				Arguments []sui_types.Argument
+			}{
+				Argument:  coinArg,
+				Arguments: []sui_types.Argument{totalArg},
+			},
+		},
+	)
+	coinsArg := ptb.Command(
+		sui_types.Command{
+			MakeMoveVec: &struct {
+				TypeTag   *move_types.TypeTag `bcs:"optional"`
+				Arguments []sui_types.Argument
+			}{TypeTag: nil, Arguments: []sui_types.Argument{splitArg}},
+		},
+	)
+	c.moveCallCommand(ptb, "create", typeTag, configArg, coinsArg, countArg, totalArg)
+	return nil
+}
+
+// openCommand open(packet: &mut RedPacketInfo<T>, lucky_accounts: vector<address>, balances: vector<u64>)
+func (c *suiRedPacketContract) openCommand(ptb *sui_types.ProgrammableTransactionBuilder, typeTag move_types.StructTag,
+	packetArg sui_types.Argument, addresses []sui_types.SuiAddress, amounts []uint64) error {
+	arg1, err := ptb.Pure(addresses)
+	if err != nil {
+		return err
+	}
+	arg2, err := ptb.Pure(amounts)
+	if err != nil {
+		return err
+	}
+	c.moveCallCommand(ptb, "open", typeTag, packetArg, arg1, arg2)
+	return nil
+}
+
+// suiOpenArgs parse the opened addresses and amounts to the vector<address> and vector<u64>
+func suiOpenArgs(params *RedPacketOpenParams) ([]sui_types.SuiAddress, []uint64, error) {
+	if len(params.Addresses) != len(params.Amounts) {
+		return nil, nil, newInvalidParamsError("the number of opened addresses is not the same as the amount")
+	}
+	addresses := make([]sui_types.SuiAddress, len(params.Addresses))
+	amounts := make([]uint64, len(params.Amounts))
+	for i := range params.Addresses {
+		address, err := sui_types.NewAddressFromHex(params.Addresses[i])
+		if err != nil {
+			return nil, nil, newInvalidParamsError("invalid open address %v", params.Addresses[i])
+		}
+		addresses[i] = *address
+		amounts[i], err = strconv.ParseUint(params.Amounts[i], 10, 64)
+		if err != nil {
+			return nil, nil, newInvalidParamsError("invalid open amount %v", params.Amounts[i])
+		}
+	}
+	return addresses, amounts, nil
+}
+
+// packetObjectArg the red packet is a shared object, the initial shared version is read from the owner
+func (c *suiRedPacketContract) packetObjectArg(ctx context.Context, packetObjectId string) (sui_types.ObjectArg, error) {
+	cli, err := c.rpcClient()
+	if err != nil {
+		return sui_types.ObjectArg{}, err
+	}
+	objectId, err := sui_types.NewObjectIdFromHex(packetObjectId)
+	if err != nil {
+		return sui_types.ObjectArg{}, newInvalidParamsError("invalid packet object id %s", packetObjectId)
+	}
+	object, err := cli.GetObject(ctx, *objectId, &types.SuiObjectDataOptions{
+		ShowOwner: true,
+	})
+	if err != nil {
+		return sui_types.ObjectArg{}, networkError(err)
+	}
+	if object.Data == nil || object.Data.Owner == nil || object.Data.Owner.Shared == nil || object.Data.Owner.Shared.InitialSharedVersion == nil {
+		return sui_types.ObjectArg{}, newRedPacketError(ErrorCodePacketNotFound, "not found red packet object "+packetObjectId)
+	}
+	return suiSharedObjectArg(*objectId, *object.Data.Owner.Shared.InitialSharedVersion), nil
+}
+
+// suiSharedObjectArg the mutable shared object argument
+func suiSharedObjectArg(objectId sui_types.ObjectID, initialSharedVersion uint64) sui_types.ObjectArg {
+	return sui_types.ObjectArg{SharedObject: &struct {
+		Id                   move_types.AccountAddress
+		InitialSharedVersion uint64
+		Mutable              bool
+	}{
+		Id:                   objectId,
+		InitialSharedVersion: initialSharedVersion,
+		Mutable:              true,
+	}}
+}
+
+func (c *suiRedPacketContract) BuildUnsignedTransaction(sender string, rpa *RedPacketAction) (*UnsignedTransaction, error) {
+	return c.BuildUnsignedTransactionWithContext(context.Background(), sender, rpa)
+}
+
+// BuildUnsignedTransactionWithContext build the TransactionData with the gas coins of sender,
+// sign the blake2b hash of SigningMessage and submit with the serialized signature.
+func (c *suiRedPacketContract) BuildUnsignedTransactionWithContext(ctx context.Context, sender string, rpa *RedPacketAction) (*UnsignedTransaction, error) {
+	tx, err := c.createTx(ctx, sender, rpa)
+	if err != nil {
+		return nil, err
+	}
+	txBytes := tx.TransactionBytes()
+	return &UnsignedTransaction{
+		ChainName:      ChainTypeSui,
+		Sender:         sender,
+		Data:           txBytes,
+		SigningMessage: append(suiTransactionIntent(), txBytes...),
+		EstimateGasFee: strconv.FormatInt(tx.EstimateGasFee, 10),
+	}, nil
+}
+
+func (c *suiRedPacketContract) SimulateAction(account base.Account, rpa *RedPacketAction) (*SimulateResult, error) {
+	return c.SimulateActionWithContext(context.Background(), account, rpa)
+}
+
+// SimulateActionWithContext dry-run the transaction built by createTx, the abort while estimating the gas budget
+// is also returned as the failed result.
+func (c *suiRedPacketContract) SimulateActionWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*SimulateResult, error) {
+	tx, err := c.createTx(ctx, account.Address(), rpa)
+	if err != nil {
+		return failedSimulateResult(err)
+	}
+	cli, err := c.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.DryRunTransaction(ctx, tx.TransactionBytes())
+	if err != nil {
+		return nil, networkError(err)
+	}
+
+	effects := resp.Effects.Data.V1
+	gasUsed := effects.GasUsed
+	totalGas := gasUsed.ComputationCost.Uint64() + gasUsed.StorageCost.Uint64() - gasUsed.StorageRebate.Uint64()
+	result := &SimulateResult{
+		Success:        effects.Status.Status == types.ExecutionStatusSuccess,
+		EstimateGasFee: strconv.FormatUint(totalGas, 10),
+		BalanceChanges: []*BalanceChange{},
+		Events:         []*RedPacketEvent{},
+	}
+	if !result.Success {
+		result.FailureMessage = effects.Status.Error
+		result.Abort = DecodeContractAbort(effects.Status.Error)
+		return result, nil
+	}
+	for _, change := range resp.BalanceChanges {
+		if change.Owner.ObjectOwnerInternal == nil || change.Owner.AddressOwner == nil {
+			continue
+		}
+		result.BalanceChanges = append(result.BalanceChanges, &BalanceChange{
+			Address:      change.Owner.AddressOwner.String(),
+			TokenAddress: change.CoinType,
+			Amount:       change.Amount,
+		})
+	}
+	result.Events, err = suiRedPacketEvents(resp.Events)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// suiRedPacketEvents parse the RedPacketEvent of the transaction in the emitted order
+func suiRedPacketEvents(events []types.SuiEvent) ([]*RedPacketEvent, error) {
+	redPacketEvents := []*RedPacketEvent{}
+	for _, event := range events {
+		if !strings.Contains(event.Type, "RedPacketEvent") {
+			continue
+		}
+		eventData, ok := event.ParsedJson.(map[string]interface{})
+		if !ok {
+			return nil, newRedPacketDataError("redpacket event data is not map[string]interface{}")
+		}
+		redPacketEvent, err := newRedPacketEvent(eventData)
+		if err != nil {
+			return nil, err
+		}
+		redPacketEvents = append(redPacketEvents, redPacketEvent)
+	}
+	return redPacketEvents, nil
+}
+
+func (c *suiRedPacketContract) QuoteCreate(account base.Account, rpa *RedPacketAction) (*CreateQuote, error) {
+	return c.QuoteCreateWithContext(context.Background(), account, rpa)
+}
+
+// QuoteCreateWithContext the service fee is charged in the red packet coin
+func (c *suiRedPacketContract) QuoteCreateWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (*CreateQuote, error) {
+	return quoteCreate(ctx, c, account, rpa)
+}
+
+func (c *suiRedPacketContract) unestimatedGasFee(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(0).SetUint64(sui.MaxGasForPay), nil
+}
+
+func (c *suiRedPacketContract) quoteTokens(rpa *RedPacketAction) (string, string) {
+	return suiCoinAddress, rpa.CreateParams.TokenAddress
+}
+
+func (c *suiRedPacketContract) sameToken(a, b string) bool {
+	return suiSameCoinType(a, b)
+}
+
+func (c *suiRedPacketContract) balanceOf(ctx context.Context, owner, token string) (*big.Int, error) {
+	address, err := sui_types.NewAddressFromHex(owner)
+	if err != nil {
+		return nil, err
+	}
+	coins, err := c.listCoins(ctx, *address, token)
+	if err != nil {
+		return nil, err
+	}
+	balance := big.NewInt(0)
+	for _, coin := range coins {
+		balance.Add(balance, big.NewInt(0).SetUint64(coin.Balance.Uint64()))
+	}
+	return balance, nil
+}
+
+// listCoins page through all coins of the coin type owned by owner
+func (c *suiRedPacketContract) listCoins(ctx context.Context, owner sui_types.SuiAddress, coinType string) ([]types.Coin, error) {
+	cli, err := c.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	var coins []types.Coin
+	var cursor *sui_types.ObjectID
+	for {
+		page, err := cli.GetCoins(ctx, owner, &coinType, cursor, suiCoinPageLimit)
+		if err != nil {
+			return nil, networkError(err)
+		}
+		coins = append(coins, page.Data...)
+		if !page.HasNextPage || page.NextCursor == nil {
+			return coins, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// suiTransactionIntent the intent prefix of the transaction data: scope TransactionData, version V0, app id Sui
+func suiTransactionIntent() []byte {
+	return []byte{0, 0, 0}
+}
+
+func (c *suiRedPacketContract) SubmitSignedTransaction(signedTx *SignedTransaction) (string, error) {
+	return c.SubmitSignedTransactionWithContext(context.Background(), signedTx)
+}
+
+func (c *suiRedPacketContract) SubmitSignedTransactionWithContext(ctx context.Context, signedTx *SignedTransaction) (string, error) {
+	if signedTx == nil || len(signedTx.Data) == 0 || len(signedTx.Signature) == 0 {
+		return "", newInvalidParamsError("invalid signed transaction")
+	}
+	cli, err := c.rpcClient()
+	if err != nil {
+		return "", err
+	}
+	resp, err := cli.ExecuteTransactionBlock(ctx, signedTx.Data, []any{base64.StdEncoding.EncodeToString(signedTx.Signature)},
+		&types.SuiTransactionBlockResponseOptions{ShowEffects: true}, types.TxnRequestTypeWaitForEffectsCert)
+	if err != nil {
+		return "", networkError(err)
+	}
+	return resp.Digest.String(), nil
+}
+
+func (c *suiRedPacketContract) FetchRedPacketCreationDetail(hash string) (*RedPacketDetail, error) {
+	return c.FetchRedPacketCreationDetailWithContext(context.Background(), hash)
+}
+
+func (c *suiRedPacketContract) FetchRedPacketCreationDetailWithContext(ctx context.Context, hash string) (detail *RedPacketDetail, err error) {
+	defer base.CatchPanicAndMapToBasicError(&err)
+
+	resp, err := c.fetchTransactionBlock(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	coinType, baseTransaction, err := toSuiBaseTransaction(hash, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	coinInfo, err := c.coinMetadata(ctx, coinType)
+	if err != nil {
+		return nil, err
+	}
+
+	coinAmount, err := getAmountBySuiEvents(resp.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	detail = &RedPacketDetail{
+		TransactionDetail: baseTransaction,
+		AmountName:        coinInfo.Name,
+		AmountDecimal:     int16(coinInfo.Decimals),
+		RedPacketAmount:   strconv.FormatUint(coinAmount, 10),
+		ChainName:         ChainTypeSui,
+		PacketObjectId:    c.packetObjectIdByObjectChanges(resp.ObjectChanges),
+		Abort:             transactionAbort(baseTransaction),
+	}
+	return detail, nil
+}
+
+func (c *suiRedPacketContract) FetchRedPacketOpenDetail(hash string) (*RedPacketOpenDetail, error) {
+	return c.FetchRedPacketOpenDetailWithContext(context.Background(), hash)
+}
+
+func (c *suiRedPacketContract) FetchRedPacketOpenDetailWithContext(ctx context.Context, hash string) (detail *RedPacketOpenDetail, err error) {
+	defer base.CatchPanicAndMapToBasicError(&err)
+
+	resp, err := c.fetchTransactionBlock(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	// open(packet, lucky_accounts, balances)
+	packageId, coinType, args, err := suiRedPacketMoveCall(resp, RPAMethodOpen)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 3 {
+		return nil, newRedPacketDataError("invalid move call args")
+	}
+	coinInfo, err := c.coinMetadata(ctx, coinType)
+	if err != nil {
+		return nil, err
+	}
+
+	baseTransaction := toSuiTransactionDetail(hash, resp)
+	baseTransaction.ToAddress = packageId
+	detail = &RedPacketOpenDetail{
+		TransactionDetail: baseTransaction,
+		AmountName:        coinInfo.Name,
+		AmountDecimal:     int16(coinInfo.Decimals),
+		ChainName:         ChainTypeSui,
+		Abort:             transactionAbort(baseTransaction),
+	}
+	detail.PacketObjectId, _ = args[0].(map[string]interface{})["objectId"].(string)
+	addresses, _ := args[1].(map[string]interface{})["value"].([]interface{})
+	amounts, _ := args[2].(map[string]interface{})["value"].([]interface{})
+	if len(addresses) != len(amounts) {
+		return nil, newRedPacketDataError("the number of opened addresses is not the same as the amount")
+	}
+	detail.Addresses = make([]string, len(addresses))
+	detail.Amounts = make([]string, len(amounts))
+	for i := range addresses {
+		detail.Addresses[i] = fmt.Sprint(addresses[i])
+		detail.Amounts[i] = fmt.Sprint(amounts[i])
+	}
+	baseTransaction.Amount = sumAmounts(detail.Amounts)
+
+	if baseTransaction.Status == base.TransactionStatusSuccess {
+		eventData, err := getSuiRedPacketEvent(resp.Events, redPacketEventOpen)
+		if err != nil {
+			return nil, err
+		}
+		detail.RemainCount, detail.RemainBalance, err = redPacketEventRemain(eventData)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return detail, nil
+}
+
+func (c *suiRedPacketContract) FetchRedPacketCloseDetail(hash string) (*RedPacketCloseDetail, error) {
+	return c.FetchRedPacketCloseDetailWithContext(context.Background(), hash)
+}
+
+func (c *suiRedPacketContract) FetchRedPacketCloseDetailWithContext(ctx context.Context, hash string) (detail *RedPacketCloseDetail, err error) {
+	defer base.CatchPanicAndMapToBasicError(&err)
+
+	resp, err := c.fetchTransactionBlock(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
-	configHex, err := sui_types.NewObjectIdFromHex(config.SuiConfigAddress)
+	// close(packet)
+	packageId, coinType, args, err := suiRedPacketMoveCall(resp, RPAMethodClose)
 	if err != nil {
 		return nil, err
 	}
-	return &suiRedPacketContract{
-		chain:        chain,
-		address:      address,
-		packageIdHex: *pkgId,
-		configHex:    *configHex,
-	}, nil
-}
-
-func (c *suiRedPacketContract) SendTransaction(account base.Account, rpa *RedPacketAction) (string, error) {
-	tx, err := c.createTx(account, rpa)
+	if len(args) < 1 {
+		return nil, newRedPacketDataError("invalid move call args")
+	}
+	coinInfo, err := c.coinMetadata(ctx, coinType)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	suiAccount, ok := account.(*sui.Account)
-	if !ok {
-		return "", errors.New("invalid account object")
+	baseTransaction := toSuiTransactionDetail(hash, resp)
+	baseTransaction.ToAddress = packageId
+	baseTransaction.Amount = "0"
+	detail = &RedPacketCloseDetail{
+		TransactionDetail: baseTransaction,
+		AmountName:        coinInfo.Name,
+		AmountDecimal:     int16(coinInfo.Decimals),
+		ChainName:         ChainTypeSui,
+		RefundAmount:      "0",
+		Abort:             transactionAbort(baseTransaction),
+	}
+	detail.PacketObjectId, _ = args[0].(map[string]interface{})["objectId"].(string)
+	if baseTransaction.Status != base.TransactionStatusSuccess {
+		return detail, nil
 	}
 
-	signedTxn, err := tx.SignWithAccount(suiAccount)
+	eventData, err := getSuiRedPacketEvent(resp.Events, redPacketEventClose)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return c.chain.SendRawTransaction(signedTxn.Value)
+	_, detail.RefundAmount, err = redPacketEventRemain(eventData)
+	if err != nil {
+		return nil, err
+	}
+	baseTransaction.Amount = detail.RefundAmount
+
+	// the refund is transferred to the creator, prefer the receiver who is not the sender
+	for _, change := range resp.BalanceChanges {
+		if change.CoinType != coinType || change.Owner.ObjectOwnerInternal == nil || change.Owner.AddressOwner == nil {
+			continue
+		}
+		if strings.HasPrefix(change.Amount, "-") {
+			continue
+		}
+		detail.Creator = change.Owner.AddressOwner.String()
+		if detail.Creator != baseTransaction.FromAddress {
+			break
+		}
+	}
+	return detail, nil
 }
 
-func (c *suiRedPacketContract) createTx(account base.Account, rpa *RedPacketAction) (*sui.Transaction, error) {
-	cli, err := c.chain.Client()
+func (c *suiRedPacketContract) WaitForConfirmation(ctx context.Context, hash string, opts *ConfirmOptions) (*RedPacketConfirmation, error) {
+	return waitForConfirmation(ctx, c, c, hash, opts)
+}
+
+// transactionStatus the sui rpc only return the executed transaction, the not found error is returned before that
+func (c *suiRedPacketContract) transactionStatus(ctx context.Context, hash string) (detail *base.TransactionDetail, err error) {
+	defer base.CatchPanicAndMapToBasicError(&err)
+
+	resp, err := c.fetchTransactionBlock(ctx, hash)
 	if err != nil {
+		if strings.Contains(err.Error(), "Could not find the referenced transaction") {
+			return nil, errTransactionNotFound
+		}
 		return nil, err
 	}
+	return toSuiTransactionDetail(hash, resp), nil
+}
 
-	tokenAddress := rpa.TokenAddress()
-	resourceType, err := types.NewResourceType(tokenAddress)
+func (c *suiRedPacketContract) fetchTransactionBlock(ctx context.Context, hash string) (*types.SuiTransactionBlockResponse, error) {
+	cli, err := c.rpcClient()
 	if err != nil {
 		return nil, err
 	}
-	typeTag := move_types.StructTag{
-		Address: *resourceType.Address,
-		Module:  move_types.Identifier(resourceType.ModuleName),
-		Name:    move_types.Identifier(resourceType.FuncName),
+	digest, err := sui_types.NewDigest(hash)
+	if err != nil {
+		return nil, err
 	}
-	configObject, err := cli.GetObject(context.Background(), c.configHex, &types.SuiObjectDataOptions{
-		ShowOwner: true,
+	resp, err := cli.GetTransactionBlock(ctx, *digest, types.SuiTransactionBlockResponseOptions{
+		ShowInput:          true,
+		ShowEffects:        true,
+		ShowEvents:         true,
+		ShowObjectChanges:  true,
+		ShowBalanceChanges: true,
 	})
 	if err != nil {
-		return nil, err
+		return nil, networkError(err)
 	}
-	if configObject.Data == nil || configObject.Data.Owner == nil || configObject.Data.Owner.Shared == nil || configObject.Data.Owner.Shared.InitialSharedVersion == nil {
-		return nil, errors.New("invalid shared config address")
+	return resp, nil
+}
+
+// rpcClient get the rpc client of the chain, the dial error is the network error
+func (c *suiRedPacketContract) rpcClient() (*client.Client, error) {
+	cli, err := c.chain.Client()
+	if err != nil {
+		return nil, networkError(err)
 	}
-	configCallArg := sui_types.ObjectArg{SharedObject: &struct {
-		Id                   move_types.AccountAddress
-		InitialSharedVersion uint64
-		Mutable              bool
-	}{
-		Id:                   c.configHex,
-		InitialSharedVersion: *configObject.Data.Owner.Shared.InitialSharedVersion,
-		Mutable:              true,
-	}}
+	return cli, nil
+}
 
-	sender, _ := sui_types.NewAddressFromHex(account.Address())
-	switch rpa.Method {
-	case RPAMethodCreate:
-		amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("amount params is not uint64")
-		}
-		amountTotal := calcTotal(amount, suiFeePoint)
+// suiPickedCoins the coins picked for the amount, sorted by the balance descending
+type suiPickedCoins struct {
+	coins []types.Coin
+	total uint64
+}
 
-		coins, err := cli.GetCoins(context.Background(), *sender, &tokenAddress, nil, 100)
-		if err != nil {
-			return nil, err
+// suiPickCoins pick the largest coins until the total covers the amount, so the balance split across
+// many small coins is spent with as few inputs as possible
+func suiPickCoins(coins []types.Coin, amount uint64, maxCount int) (*suiPickedCoins, error) {
+	sorted := make([]types.Coin, len(coins))
+	copy(sorted, coins)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Balance.Uint64() > sorted[j].Balance.Uint64()
+	})
+	picked := &suiPickedCoins{}
+	for _, coin := range sorted {
+		if picked.total >= amount && len(picked.coins) > 0 {
+			break
 		}
-		var pickedCoins *types.PickedCoins
-		var pickedGasCoins *types.PickedCoins
-		if tokenAddress == suiCoinAddress {
-			pickedCoins = nil
-			pickedGasCoins, err = types.PickupCoins(coins, *big.NewInt(0).SetUint64(amountTotal), sui.MaxGasForPay, 100, 0)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			pickedCoins, err = types.PickupCoins(coins, *big.NewInt(0).SetUint64(amountTotal), 0, 100, 0)
-			if err != nil {
-				return nil, err
-			}
-			pickedGasCoins, err = c.chain.PickGasCoins(*sender, sui.MaxGasForPay)
-			if err != nil {
-				return nil, err
-			}
+		picked.coins = append(picked.coins, coin)
+		picked.total += coin.Balance.Uint64()
+	}
+	if len(picked.coins) == 0 || picked.total < amount {
+		return nil, &RedPacketError{Code: ErrorCodeInsufficientBalance, Message: "insufficient balance"}
+	}
+	if len(picked.coins) > maxCount {
+		return nil, &RedPacketError{
+			Code:    ErrorCodeInsufficientBalance,
+			Message: fmt.Sprintf("the amount needs %d coins, more than %d coins of a transaction, merge the coins first", len(picked.coins), maxCount),
 		}
+	}
+	return picked, nil
+}
 
-		maxGasBudget := base.Min(pickedGasCoins.SuggestMaxGasBudget(), sui.MaxGasForPay)
-		gasPrice, _ := c.chain.CachedGasPrice()
-
-		return c.chain.EstimateTransactionFeeAndRebuildTransactionBCS(maxGasBudget, func(gasBudget uint64) (*sui.Transaction, error) {
-			ptb := sui_types.NewProgrammableTransactionBuilder()
-			var arg0, arg1, arg2, arg3 sui_types.Argument
-			amtArg, err := ptb.Pure(amountTotal)
-			if err != nil {
-				return nil, err
-			}
-			if tokenAddress == suiCoinAddress {
-				arg := ptb.Command(
-					sui_types.Command{
-						SplitCoins: &struct {
-							Argument  sui_types.Argument
-							Arguments []sui_types.Argument
-						}{
-							Argument:  sui_types.Argument{GasCoin: &lib.EmptyEnum{}},
-							Arguments: []sui_types.Argument{amtArg},
-						},
-					},
-				)
-				arg1 = ptb.Command(
-					sui_types.Command{
-						MakeMoveVec: &struct {
-							TypeTag   *move_types.TypeTag `bcs:"optional"`
-							Arguments []sui_types.Argument
-						}{TypeTag: nil, Arguments: []sui_types.Argument{arg}},
-					},
-				)
-			} else {
-				coinArgs := make([]sui_types.ObjectArg, len(pickedCoins.Coins))
-				for idx, coin := range pickedCoins.Coins {
-					coinArgs[idx] = sui_types.ObjectArg{
-						ImmOrOwnedObject: coin.Reference(),
-					}
-				}
-				arg1, err = ptb.MakeObjList(coinArgs)
-				if err != nil {
-					return nil, err
-				}
-			}
+func suiCoinRefs(coins []types.Coin) []*sui_types.ObjectRef {
+	refs := make([]*sui_types.ObjectRef, len(coins))
+	for i := range coins {
+		refs[i] = coins[i].Reference()
+	}
+	return refs
+}
 
-			arg0, err = ptb.Obj(configCallArg)
-			if err != nil {
-				return nil, err
-			}
-			arg2, err = ptb.Pure(uint64(rpa.CreateParams.Count))
-			if err != nil {
-				return nil, err
-			}
-			arg3 = amtArg
-
-			ptb.Command(
-				sui_types.Command{
-					MoveCall: &sui_types.ProgrammableMoveCall{
-						Package:  c.packageIdHex,
-						Module:   move_types.Identifier(suiPackage),
-						Function: move_types.Identifier("create"),
-						TypeArguments: []move_types.TypeTag{
-							{Struct: &typeTag},
-						},
-						Arguments: []sui_types.Argument{
-							arg0, arg1, arg2, arg3,
-						},
-					},
-				},
-			)
-			pt := ptb.Finish()
-			tx := sui_types.NewProgrammable(*sender, pickedGasCoins.CoinRefs(), pt, gasBudget, gasPrice)
-			txBytes, err := bcs.Marshal(tx)
-			if err != nil {
-				return nil, err
-			}
-			return &sui.Transaction{TxnBytes: txBytes}, nil
-		})
-	case RPAMethodOpen:
-		if len(rpa.OpenParams.PacketObjectId) == 0 {
-			return nil, errors.New("invalid redPacketObjectId")
-		}
-		redPacketObjectId, err := lib.NewHexData(rpa.OpenParams.PacketObjectId)
+func suiCoinArgs(ptb *sui_types.ProgrammableTransactionBuilder, coins []types.Coin) ([]sui_types.Argument, error) {
+	args := make([]sui_types.Argument, len(coins))
+	for i := range coins {
+		arg, err := ptb.Obj(sui_types.ObjectArg{ImmOrOwnedObject: coins[i].Reference()})
 		if err != nil {
 			return nil, err
 		}
-		addresses := make([]*lib.HexData, len(rpa.OpenParams.Addresses))
-		for i := range rpa.OpenParams.Addresses {
-			addresses[i], err = lib.NewHexData(rpa.OpenParams.Addresses[i])
-			if err != nil {
-				return nil, err
-			}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// suiMergeCoins merge the coins into the primary coin, suiMaxMergeCoins coins per command
+func suiMergeCoins(ptb *sui_types.ProgrammableTransactionBuilder, primary sui_types.Argument, coins []sui_types.Argument) {
+	for start := 0; start < len(coins); start += suiMaxMergeCoins {
+		end := start + suiMaxMergeCoins
+		if end > len(coins) {
+			end = len(coins)
 		}
-		args := []interface{}{
-			redPacketObjectId,
-			addresses,
-			rpa.OpenParams.Amounts,
-		}
-		return c.chain.BaseMoveCall(
-			account.Address(),
-			c.packageIdHex.String(),
-			suiPackage,
-			"open",
-			[]string{rpa.OpenParams.TokenAddress},
-			args,
-			0,
+		ptb.Command(
+			sui_types.Command{
+				MergeCoins: &struct {
+					Argument  sui_types.Argument
+					Arguments []sui_types.Argument
+				}{
+					Argument:  primary,
+					Arguments: coins[start:end],
+				},
+			},
 		)
-	case RPAMethodClose:
-		if len(rpa.CloseParams.PacketObjectId) == 0 {
-			return nil, errors.New("invalid redPacketObjectId")
-		}
-		packetId, err := lib.NewHexData(rpa.CloseParams.PacketObjectId)
-		if err != nil {
-			return nil, err
+	}
+}
+
+func (c *suiRedPacketContract) coinMetadata(ctx context.Context, coinType string) (*types.SuiCoinMetadata, error) {
+	cli, err := c.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	coinInfo, err := cli.GetCoinMetadata(ctx, coinType)
+	if err != nil {
+		if coinType == suiCoinAddress {
+			return &types.SuiCoinMetadata{
+				Decimals: 9,
+				Symbol:   "SUI",
+				Name:     "SUI",
+			}, nil
 		}
-		args := []interface{}{
-			packetId,
-		}
-		return c.chain.BaseMoveCall(
-			account.Address(),
-			c.packageIdHex.String(),
-			suiPackage,
-			"close",
-			[]string{rpa.CloseParams.TokenAddress},
-			args,
-			0,
-		)
-	default:
-		return nil, fmt.Errorf("unsopported red packet method %s", rpa.Method)
+		return nil, networkError(err)
+	}
+	return coinInfo, nil
+}
+
+func (c *suiRedPacketContract) PrepareCreate(owner string, rpa *RedPacketAction) (*RedPacketAction, error) {
+	return c.PrepareCreateWithContext(context.Background(), owner, rpa)
+}
+
+// PrepareCreateWithContext the create action can be sent directly, there is no approve on sui
+func (c *suiRedPacketContract) PrepareCreateWithContext(ctx context.Context, owner string, rpa *RedPacketAction) (*RedPacketAction, error) {
+	if rpa.Method != RPAMethodCreate || rpa.CreateParams == nil {
+		return nil, newInvalidParamsError("invalid create params")
 	}
+	return nil, nil
+}
+
+func (c *suiRedPacketContract) FetchRedPacketState(tokenAddress string, packetId int64, packetObjectId string) (*RedPacketState, error) {
+	return c.FetchRedPacketStateWithContext(context.Background(), tokenAddress, packetId, packetObjectId)
 }
 
-func (c *suiRedPacketContract) FetchRedPacketCreationDetail(hash string) (detail *RedPacketDetail, err error) {
+func (c *suiRedPacketContract) FetchRedPacketStateWithContext(ctx context.Context, tokenAddress string, packetId int64, packetObjectId string) (state *RedPacketState, err error) {
 	defer base.CatchPanicAndMapToBasicError(&err)
 
-	cli, err := c.chain.Client()
+	cli, err := c.rpcClient()
 	if err != nil {
 		return nil, err
 	}
-	digest, err := sui_types.NewDigest(hash)
+	objectId, err := sui_types.NewObjectIdFromHex(packetObjectId)
 	if err != nil {
-		return nil, err
+		return nil, newInvalidParamsError("invalid packet object id %s", packetObjectId)
 	}
-	resp, err := cli.GetTransactionBlock(context.Background(), *digest, types.SuiTransactionBlockResponseOptions{
-		ShowInput:   true,
-		ShowEffects: true,
-		ShowEvents:  true,
+	object, err := cli.GetObject(ctx, *objectId, &types.SuiObjectDataOptions{
+		ShowType:    true,
+		ShowContent: true,
 	})
 	if err != nil {
-		return nil, err
+		return nil, networkError(err)
 	}
-	coinType, baseTransaction, err := toSuiBaseTransaction(hash, resp)
-	if err != nil {
-		return nil, err
+	if object.Data == nil || object.Data.Content == nil || object.Data.Content.Data.MoveObject == nil {
+		return nil, newRedPacketError(ErrorCodePacketNotFound, "not found red packet object "+packetObjectId)
 	}
-
-	coinInfo, err := cli.GetCoinMetadata(context.Background(), coinType)
+	fields := object.Data.Content.Data.MoveObject.Fields
+	remainCount, err := strconv.ParseInt(fmt.Sprint(fields["remain_count"]), 10, 64)
 	if err != nil {
-		if coinType == suiCoinAddress {
-			coinInfo = &types.SuiCoinMetadata{
-				Decimals: 9,
-				Symbol:   "SUI",
-				Name:     "SUI",
-			}
-		} else {
-			return nil, err
-		}
+		return nil, newRedPacketDataError("red packet remain_count is not u64")
 	}
-
-	coinAmount, err := getAmountBySuiEvents(resp.Events)
-	if err != nil {
-		return nil, err
+	remainBalance := fmt.Sprint(fields["balance"])
+	if _, ok := big.NewInt(0).SetString(remainBalance, 10); !ok {
+		return nil, newRedPacketDataError("red packet balance is not u64")
 	}
+	creator, _ := fields["creator"].(string)
 
-	detail = &RedPacketDetail{
-		TransactionDetail: baseTransaction,
-		AmountName:        coinInfo.Name,
-		AmountDecimal:     int16(coinInfo.Decimals),
-		RedPacketAmount:   strconv.FormatUint(coinAmount, 10),
-		ChainName:         ChainTypeSui,
+	coinType := tokenAddress
+	if object.Data.Type != nil {
+		if objectCoinType := suiCoinTypeOfObject(*object.Data.Type); objectCoinType != "" {
+			coinType = objectCoinType
+		}
 	}
-	return detail, nil
+	return &RedPacketState{
+		TokenAddress:  coinType,
+		Creator:       creator,
+		RemainCount:   remainCount,
+		RemainBalance: remainBalance,
+		Valid:         remainCount > 0,
+	}, nil
 }
 
 func (c *suiRedPacketContract) EstimateFee(rpa *RedPacketAction) (string, error) {
+	return c.EstimateFeeWithContext(context.Background(), rpa)
+}
+
+func (c *suiRedPacketContract) EstimateFeeWithContext(ctx context.Context, rpa *RedPacketAction) (string, error) {
 	switch rpa.Method {
 	case RPAMethodCreate:
 		if nil == rpa.CreateParams {
-			return "", errors.New("invalid create params")
+			return "", newInvalidParamsError("invalid create params")
 		}
 		amount, err := strconv.ParseUint(rpa.CreateParams.Amount, 10, 64)
+		if err != nil {
+			return "", newInvalidParamsError("amount params is not uint64")
+		}
+		config, err := c.fetchConfig(ctx)
 		if err != nil {
 			return "", err
 		}
-		total := calcTotal(amount, suiFeePoint)
+		total := calcTotal(amount, config.feePointOf(rpa.CreateParams.TokenAddress))
 		return strconv.FormatUint(total-amount, 10), nil
 	default:
-		return "", errors.New("method invalid")
+		return "", newInvalidParamsError("method invalid")
 	}
 }
 
 func (c *suiRedPacketContract) EstimateGasFee(account base.Account, rpa *RedPacketAction) (string, error) {
-	tx, err := c.createTx(account, rpa)
+	return c.EstimateGasFeeWithContext(context.Background(), account, rpa)
+}
+
+func (c *suiRedPacketContract) EstimateGasFeeWithContext(ctx context.Context, account base.Account, rpa *RedPacketAction) (string, error) {
+	tx, err := c.createTx(ctx, account.Address(), rpa)
 	if err != nil {
 		return "", err
 	}
@@ -344,6 +1088,117 @@ func (c *suiRedPacketContract) EstimateGasFee(account base.Account, rpa *RedPack
 	return feeString, nil
 }
 
+// packetObjectIdByObjectChanges find the red packet object created by this package
+func (c *suiRedPacketContract) packetObjectIdByObjectChanges(changes []lib.TagJson[types.ObjectChange]) string {
+	typePrefix := c.packageIdHex.String() + "::" + suiPackage + "::"
+	for _, change := range changes {
+		created := change.Data.Created
+		if created == nil || !strings.HasPrefix(created.ObjectType, typePrefix) {
+			continue
+		}
+		return created.ObjectId.String()
+	}
+	return ""
+}
+
+// fetchConfig get the shared config object, parse the fee_point and the per coin fee points from coin_configs
+func (c *suiRedPacketContract) fetchConfig(ctx context.Context) (*suiRedPacketConfig, error) {
+	cli, err := c.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	configObject, err := cli.GetObject(ctx, c.configHex, &types.SuiObjectDataOptions{
+		ShowOwner:   true,
+		ShowContent: true,
+	})
+	if err != nil {
+		return nil, networkError(err)
+	}
+	if configObject.Data == nil || configObject.Data.Owner == nil || configObject.Data.Owner.Shared == nil || configObject.Data.Owner.Shared.InitialSharedVersion == nil {
+		return nil, newInvalidParamsError("invalid shared config address")
+	}
+	config := &suiRedPacketConfig{
+		InitialSharedVersion: *configObject.Data.Owner.Shared.InitialSharedVersion,
+		FeePoint:             suiDefaultFeePoint,
+		CoinFeePoints:        make(map[string]uint64),
+	}
+	if configObject.Data.Content == nil || configObject.Data.Content.Data.MoveObject == nil {
+		return config, nil
+	}
+	fields := configObject.Data.Content.Data.MoveObject.Fields
+	if feePoint, ok := fields["fee_point"]; ok {
+		config.FeePoint, err = strconv.ParseUint(fmt.Sprint(feePoint), 10, 64)
+		if err != nil {
+			return nil, newRedPacketDataError("config fee_point is not u64")
+		}
+	}
+	// coin_configs: VecMap<TypeName, CoinConfig>, the nested struct is {"type": ..., "fields": {...}}
+	contents, _ := suiMoveFields(fields["coin_configs"])["contents"].([]interface{})
+	for _, content := range contents {
+		entry := suiMoveFields(content)
+		coinType, ok := entry["key"].(string)
+		if !ok {
+			coinType, _ = suiMoveFields(entry["key"])["name"].(string)
+		}
+		feePoint, err := strconv.ParseUint(fmt.Sprint(suiMoveFields(entry["value"])["fee_point"]), 10, 64)
+		if coinType == "" || err != nil {
+			return nil, newRedPacketDataError("invalid config coin_configs")
+		}
+		config.CoinFeePoints[coinType] = feePoint
+	}
+	return config, nil
+}
+
+// suiMoveFields return the fields of the nested move struct value
+func suiMoveFields(value interface{}) map[string]interface{} {
+	valueMap, _ := value.(map[string]interface{})
+	if fields, ok := valueMap["fields"].(map[string]interface{}); ok {
+		return fields
+	}
+	return valueMap
+}
+
+// suiSameCoinType compare the coin types, the address may be short or without 0x prefix (TypeName)
+func suiSameCoinType(a, b string) bool {
+	if a == b {
+		return true
+	}
+	typeA, err := types.NewResourceType("0x" + strings.TrimPrefix(a, "0x"))
+	if err != nil {
+		return false
+	}
+	typeB, err := types.NewResourceType("0x" + strings.TrimPrefix(b, "0x"))
+	if err != nil {
+		return false
+	}
+	return *typeA.Address == *typeB.Address && typeA.ModuleName == typeB.ModuleName && typeA.FuncName == typeB.FuncName
+}
+
+// suiCoinTypeOfObject get the coin type from generic object type, eg. 0x1::red_packet::RedPacketInfo<0x2::sui::SUI>
+func suiCoinTypeOfObject(objectType string) string {
+	start := strings.Index(objectType, "<")
+	end := strings.LastIndex(objectType, ">")
+	if start < 0 || end <= start {
+		return ""
+	}
+	return objectType[start+1 : end]
+}
+
+// getSuiRedPacketEvent find the RedPacketEvent with event type
+func getSuiRedPacketEvent(events []types.SuiEvent, eventType int) (map[string]interface{}, error) {
+	for _, event := range events {
+		if !strings.Contains(event.Type, "RedPacketEvent") {
+			continue
+		}
+		fields := event.ParsedJson.(map[string]interface{})
+		if fmt.Sprint(fields["event_type"]) != strconv.Itoa(eventType) {
+			continue
+		}
+		return fields, nil
+	}
+	return nil, newRedPacketDataError(fmt.Sprintf("not found %s RedPacketEvent", redPacketEventName(eventType)))
+}
+
 func getAmountBySuiEvents(events []types.SuiEvent) (uint64, error) {
 	for _, event := range events {
 		if !strings.Contains(event.Type, "RedPacketEvent") {
@@ -352,71 +1207,92 @@ func getAmountBySuiEvents(events []types.SuiEvent) (uint64, error) {
 		fields := event.ParsedJson.(map[string]interface{})
 		remainBalance, err := strconv.ParseUint(fields["remain_balance"].(string), 10, 64)
 		if err != nil {
-			return 0, err
+			return 0, newRedPacketDataError("remain_balance is not uint64")
 		}
 		return remainBalance, nil
 	}
-	return 0, errors.New("not found RedPacketEvent")
+	return 0, newRedPacketDataError("not found RedPacketEvent")
 }
 
 func toSuiBaseTransaction(hash string, resp *types.SuiTransactionBlockResponse) (string, *base.TransactionDetail, error) {
-	var coinType string
+	toAddress, coinType, args, err := suiRedPacketMoveCall(resp, RPAMethodCreate)
+	if err != nil {
+		return coinType, nil, err
+	}
+	if len(args) < 4 {
+		return coinType, nil, newRedPacketDataError("invalid move call args")
+	}
+	inputCoinArg, ok := args[len(args)-1].(map[string]interface{})
+	if !ok {
+		return coinType, nil, newRedPacketDataError("invalid input args")
+	}
+	inputCoinAmount, _ := inputCoinArg["value"].(string)
+	if inputCoinAmount == "" {
+		return coinType, nil, newRedPacketDataError("not found input coin amount")
+	}
+
+	detail := toSuiTransactionDetail(hash, resp)
+	detail.ToAddress = toAddress
+	detail.Amount = inputCoinAmount
+	return coinType, detail, nil
+}
+
+// suiRedPacketMoveCall find the red packet move call of function in the programmable transaction,
+// return the package, coin type and the transaction inputs of call arguments (nil if the argument is not an input).
+func suiRedPacketMoveCall(resp *types.SuiTransactionBlockResponse, function string) (string, string, []interface{}, error) {
 	if nil == resp.Transaction {
-		return coinType, nil, errors.New("not found transaction")
+		return "", "", nil, newRedPacketDataError("not found transaction")
 	}
 	if nil == resp.Transaction.Data.Data.V1 {
-		return coinType, nil, errors.New("not programmable transaction")
+		return "", "", nil, newRedPacketDataError("not programmable transaction")
 	}
 	programmableTransaction := resp.Transaction.Data.Data.V1.Transaction.Data.ProgrammableTransaction
 	if nil == programmableTransaction {
-		return coinType, nil, errors.New("not programmable transaction")
+		return "", "", nil, newRedPacketDataError("not programmable transaction")
 	}
 
-	var inputCoinAmount string
-	var toAddress string
 	for _, command := range programmableTransaction.Commands {
 		moveCallCommand := command.(map[string]interface{})
-		if moveCallData, ok := moveCallCommand["MoveCall"]; ok {
-			moveCallMap := moveCallData.(map[string]interface{})
-			if moveCallMap["module"] != "red_packet" || moveCallMap["function"] != "create" {
-				continue
-			}
-			toAddress = moveCallMap["package"].(string)
-			typeArgs := moveCallMap["type_arguments"].([]interface{})
-			if len(typeArgs) == 0 {
-				return coinType, nil, errors.New("invalid type args")
-			}
-			coinType = typeArgs[0].(string)
+		moveCallData, ok := moveCallCommand["MoveCall"]
+		if !ok {
+			continue
+		}
+		moveCallMap := moveCallData.(map[string]interface{})
+		if moveCallMap["module"] != suiPackage || moveCallMap["function"] != function {
+			continue
+		}
+		packageId := moveCallMap["package"].(string)
+		typeArgs := moveCallMap["type_arguments"].([]interface{})
+		if len(typeArgs) == 0 {
+			return packageId, "", nil, newRedPacketDataError("invalid type args")
+		}
+		coinType := typeArgs[0].(string)
 
-			args := moveCallMap["arguments"].([]interface{})
-			if len(args) < 4 {
-				return coinType, nil, errors.New("invalid move call args")
+		callArgs := moveCallMap["arguments"].([]interface{})
+		inputs := make([]interface{}, len(callArgs))
+		for i, arg := range callArgs {
+			inputIndex, ok := arg.(map[string]interface{})["Input"].(float64)
+			if !ok {
+				continue
 			}
-			inputCoinArg := args[len(args)-1].(map[string]interface{})
-			inputIndex := int(inputCoinArg["Input"].(float64))
-
-			if len(programmableTransaction.Inputs) <= inputIndex {
-				return coinType, nil, errors.New("invalid input args")
+			if len(programmableTransaction.Inputs) <= int(inputIndex) {
+				return packageId, coinType, nil, newRedPacketDataError("invalid input args")
 			}
-
-			inputCoinAmount = programmableTransaction.Inputs[inputIndex].(map[string]interface{})["value"].(string)
+			inputs[i] = programmableTransaction.Inputs[int(inputIndex)]
 		}
+		return packageId, coinType, inputs, nil
 	}
-	if toAddress == "" {
-		return coinType, nil, errors.New("invalid to package address")
-	}
-	if inputCoinAmount == "" {
-		return coinType, nil, errors.New("not found input coin amount")
-	}
+	return "", "", nil, newRedPacketDataError("invalid to package address")
+}
 
+// toSuiTransactionDetail fill sender, gas fee, status and timestamp of the transaction
+func toSuiTransactionDetail(hash string, resp *types.SuiTransactionBlockResponse) *base.TransactionDetail {
 	gasUsed := resp.Effects.Data.V1.GasUsed
 	totalGas := gasUsed.ComputationCost.Uint64() + gasUsed.StorageCost.Uint64() - gasUsed.StorageRebate.Uint64()
 
 	detail := &base.TransactionDetail{
 		HashString:   hash,
 		FromAddress:  resp.Transaction.Data.Data.V1.Sender.String(),
-		ToAddress:    toAddress,
-		Amount:       inputCoinAmount,
 		EstimateFees: strconv.FormatUint(totalGas, 10),
 	}
 	if resp.TimestampMs != nil {
@@ -429,5 +1305,5 @@ func toSuiBaseTransaction(hash string, resp *types.SuiTransactionBlockResponse)
 		detail.Status = base.TransactionStatusFailure
 		detail.FailureMessage = status.Error
 	}
-	return coinType, detail, nil
+	return detail
 }
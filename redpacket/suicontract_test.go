@@ -3,11 +3,17 @@ package redpacket
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 
+	"github.com/coming-chat/go-red-packet/redpacket/chainstub"
+	"github.com/coming-chat/go-sui/v2/client"
+	"github.com/coming-chat/go-sui/v2/sui_types"
 	"github.com/coming-chat/go-sui/v2/types"
 	"github.com/coming-chat/wallet-SDK/core/sui"
+	"github.com/fardream/go-bcs/bcs"
 	"github.com/stretchr/testify/require"
 )
 
@@ -45,7 +51,7 @@ func TestSui_RedPacketCreate(t *testing.T) {
 	createAction, err := NewRedPacketActionCreate(SuiCoinType, 1, "10000000")
 	require.Nil(t, err)
 
-	txn, err := suiContract.createTx(account, createAction)
+	txn, err := suiContract.createTx(context.Background(), account.Address(), createAction)
 	require.Nil(t, err)
 
 	simulateCheck(t, chain, txn, true)
@@ -67,3 +73,202 @@ func simulateCheck(t *testing.T, chain *sui.Chain, txn *sui.Transaction, showJso
 	t.Log("simulate gas price = ", resp.Effects.Data.GasFee())
 	return resp
 }
+
+func TestSui_ConfigFeePointOf(t *testing.T) {
+	config := suiRedPacketConfig{
+		FeePoint: 250,
+		CoinFeePoints: map[string]uint64{
+			"0000000000000000000000000000000000000000000000000000000000000002::sui::SUI": 100,
+		},
+	}
+	require.Equal(t, uint64(100), config.feePointOf(SuiCoinType))
+	require.Equal(t, uint64(250), config.feePointOf("0x5d4b302506645c37ff133b98c4b50a5ae14841659738d6d733d59d0d217a93bf::coin::COIN"))
+}
+
+func TestSui_FetchConfig(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	require.Nil(t, server.HandleFixture("sui_getObject", fixturePath("sui/config_object.json")))
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	suiContract := contract.(*suiRedPacketContract)
+
+	// the coin_configs key is the {name} TypeName struct or the plain type name
+	config, err := suiContract.fetchConfig(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), config.InitialSharedVersion)
+	require.Equal(t, uint64(250), config.FeePoint)
+	require.Equal(t, map[string]uint64{
+		"0000000000000000000000000000000000000000000000000000000000000002::sui::SUI":   100,
+		"5d4b302506645c37ff133b98c4b50a5ae14841659738d6d733d59d0d217a93bf::coin::COIN": 50,
+	}, config.CoinFeePoints)
+	require.Equal(t, uint64(100), config.feePointOf(SuiCoinType))
+
+	// the config without fee_point uses the default
+	var object map[string]interface{}
+	loadFixture(t, "sui/config_object.json", &object)
+	fields := object["data"].(map[string]interface{})["content"].(map[string]interface{})["fields"].(map[string]interface{})
+	delete(fields, "fee_point")
+	server.HandleResult("sui_getObject", object)
+	config, err = suiContract.fetchConfig(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, uint64(suiDefaultFeePoint), config.FeePoint)
+	require.Len(t, config.CoinFeePoints, 2)
+
+	// the coin config without fee_point is invalid
+	fields["coin_configs"].(map[string]interface{})["fields"].(map[string]interface{})["contents"].([]interface{})[1].(map[string]interface{})["fields"].(map[string]interface{})["value"] = map[string]interface{}{}
+	server.HandleResult("sui_getObject", object)
+	_, err = suiContract.fetchConfig(context.Background())
+	var dataErr *RedPacketDataError
+	require.True(t, errors.As(err, &dataErr))
+}
+
+func suiTestCoinJSON(id int, balance uint64) string {
+	return fmt.Sprintf(`{"coinType":"0x2::sui::SUI","coinObjectId":"0x%064x","version":"1","digest":"%s","balance":"%d","previousTransaction":"%s"}`,
+		id, fixtureSuiDigest, balance, fixtureSuiDigest)
+}
+
+func suiTestCoins(t *testing.T, balances ...uint64) []types.Coin {
+	coins := make([]types.Coin, len(balances))
+	for i, balance := range balances {
+		require.Nil(t, json.Unmarshal([]byte(suiTestCoinJSON(i+1, balance)), &coins[i]))
+	}
+	return coins
+}
+
+func Test_suiPickCoins(t *testing.T) {
+	coins := suiTestCoins(t, 10, 50, 20, 30)
+	picked, err := suiPickCoins(coins, 70, 10)
+	require.Nil(t, err)
+	require.Equal(t, uint64(80), picked.total)
+	require.Len(t, picked.coins, 2)
+	require.Equal(t, uint64(50), picked.coins[0].Balance.Uint64())
+	require.Equal(t, uint64(30), picked.coins[1].Balance.Uint64())
+	// the input coins are not sorted
+	require.Equal(t, uint64(10), coins[0].Balance.Uint64())
+
+	_, err = suiPickCoins(coins, 111, 10)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = suiPickCoins(coins, 100, 3)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = suiPickCoins(nil, 0, 3)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+}
+
+func TestSui_ListCoinsPages(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.Handle("suix_getCoins", func(params []json.RawMessage) (interface{}, error) {
+		if string(params[2]) == "null" {
+			return json.RawMessage(fmt.Sprintf(`{"data":[%s,%s],"nextCursor":"0x%064x","hasNextPage":true}`,
+				suiTestCoinJSON(1, 100), suiTestCoinJSON(2, 200), 2)), nil
+		}
+		return json.RawMessage(fmt.Sprintf(`{"data":[%s],"nextCursor":null,"hasNextPage":false}`, suiTestCoinJSON(3, 300))), nil
+	})
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	balance, err := contract.(*suiRedPacketContract).balanceOf(context.Background(), fixtureSuiCreator, SuiCoinType)
+	require.Nil(t, err)
+	require.Equal(t, "600", balance.String())
+
+	requests := server.Requests("suix_getCoins")
+	require.Len(t, requests, 2)
+	require.Equal(t, fmt.Sprintf(`"0x%064x"`, 2), string(requests[1].Params[2]))
+}
+
+func Test_suiOpenArgs(t *testing.T) {
+	addresses, amounts, err := suiOpenArgs(&RedPacketOpenParams{
+		Addresses: []string{fixtureSuiCreator, "0x2"},
+		Amounts:   []string{"100", "200"},
+	})
+	require.Nil(t, err)
+	require.Equal(t, fixtureSuiCreator, addresses[0].String())
+	require.Equal(t, []uint64{100, 200}, amounts)
+
+	_, _, err = suiOpenArgs(&RedPacketOpenParams{Addresses: []string{"0x2"}, Amounts: []string{"-1"}})
+	require.ErrorIs(t, err, ErrInvalidParams)
+	_, _, err = suiOpenArgs(&RedPacketOpenParams{Addresses: []string{"0x2"}})
+	require.ErrorIs(t, err, ErrInvalidParams)
+}
+
+func TestSui_PacketObjectArg(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("sui_getObject", json.RawMessage(`{"data":{"objectId":"`+fixtureSuiPacket+`","version":"25","digest":"FnqbqF7YJekTNEMkZJMcujSouSfd4CzTacotg2LmSqeV","owner":{"Shared":{"initial_shared_version":21}}}}`))
+
+	contract, err := NewSuiRedPacketContract(sui.NewChainWithRpcUrl(server.URL), fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	arg, err := contract.(*suiRedPacketContract).packetObjectArg(context.Background(), fixtureSuiPacket)
+	require.Nil(t, err)
+	require.NotNil(t, arg.SharedObject)
+	require.Equal(t, fixtureSuiPacket, arg.SharedObject.Id.String())
+	require.Equal(t, uint64(21), arg.SharedObject.InitialSharedVersion)
+	require.True(t, arg.SharedObject.Mutable)
+
+	// the owned object is not a red packet
+	server.HandleResult("sui_getObject", json.RawMessage(`{"data":{"objectId":"`+fixtureSuiPacket+`","version":"25","digest":"FnqbqF7YJekTNEMkZJMcujSouSfd4CzTacotg2LmSqeV","owner":{"AddressOwner":"`+fixtureSuiCreator+`"}}}`))
+	_, err = contract.(*suiRedPacketContract).packetObjectArg(context.Background(), fixtureSuiPacket)
+	require.ErrorIs(t, err, ErrPacketNotFound)
+}
+
+// fakeSuiChain build the transaction with the max gas budget, the rpc calls go to the stub server
+type fakeSuiChain struct {
+	SuiChain
+	url string
+}
+
+func (f *fakeSuiChain) Client() (*client.Client, error) {
+	return client.Dial(f.url)
+}
+
+func (f *fakeSuiChain) CachedGasPrice() (uint64, error) {
+	return 1000, nil
+}
+
+func (f *fakeSuiChain) EstimateTransactionFeeAndRebuildTransactionBCS(maxGasBudget uint64, buildTransaction func(gasBudget uint64) (*sui.Transaction, error)) (*sui.Transaction, error) {
+	return buildTransaction(maxGasBudget)
+}
+
+func TestSui_BuildBatchTx(t *testing.T) {
+	server := chainstub.NewJSONRPCServer()
+	defer server.Close()
+	server.HandleResult("sui_getObject", json.RawMessage(`{"data":{"objectId":"`+fixtureSuiPacket+`","version":"25","digest":"FnqbqF7YJekTNEMkZJMcujSouSfd4CzTacotg2LmSqeV","owner":{"Shared":{"initial_shared_version":21}}}}`))
+	server.HandleResult("suix_getCoins", json.RawMessage(fmt.Sprintf(`{"data":[%s],"nextCursor":null,"hasNextPage":false}`, suiTestCoinJSON(1, sui.MaxGasForPay))))
+
+	contract, err := NewSuiRedPacketContract(&fakeSuiChain{url: server.URL}, fixtureSuiPackage, &ContractConfig{
+		SuiConfigAddress: fixtureSuiConfig,
+	})
+	require.Nil(t, err)
+	open1, err := NewSuiRedpacketActionOpen(SuiCoinType, fixtureSuiPacket, []string{fixtureSuiCreator}, []string{"100"})
+	require.Nil(t, err)
+	open2, err := NewSuiRedpacketActionOpen(SuiCoinType, fixtureSuiPacket, []string{"0x2"}, []string{"200"})
+	require.Nil(t, err)
+	closeAction, err := NewSuiRedPacketActionClose(SuiCoinType, fixtureSuiPacket, fixtureSuiCreator, "")
+	require.Nil(t, err)
+
+	tx, err := contract.(*suiRedPacketContract).buildBatchTx(context.Background(), fixtureSuiCreator, []*RedPacketAction{open1, open2, closeAction})
+	require.Nil(t, err)
+	var txData sui_types.TransactionData
+	_, err = bcs.Unmarshal(tx.TxnBytes, &txData)
+	require.Nil(t, err)
+	pt := txData.V1.Kind.ProgrammableTransaction
+	require.Len(t, pt.Commands, 3)
+	require.Equal(t, "open", string(pt.Commands[0].MoveCall.Function))
+	require.Equal(t, "open", string(pt.Commands[1].MoveCall.Function))
+	require.Equal(t, "close", string(pt.Commands[2].MoveCall.Function))
+	// the packet object is shared by the calls, and 2 pure vectors of each open
+	require.Len(t, pt.Inputs, 5)
+	require.Equal(t, *pt.Commands[0].MoveCall.Arguments[0].Input, *pt.Commands[2].MoveCall.Arguments[0].Input)
+	// only sui_getObject once for the packet
+	require.Len(t, server.Requests("sui_getObject"), 1)
+
+	_, err = contract.(*suiRedPacketContract).buildBatchTx(context.Background(), fixtureSuiCreator, nil)
+	require.ErrorIs(t, err, ErrInvalidParams)
+}